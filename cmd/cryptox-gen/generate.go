@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const cryptoxPath = "github.com/nuntiodev/x/cryptox"
+
+// generate type checks the package in dir, ignoring the previously generated output file, and returns the
+// source of the generated methods. Structs which are skipped when no names are given are reported to warnings.
+func generate(dir, output string, names []string, warnings io.Writer) ([]byte, error) {
+	pkg, err := loadPackage(dir, output)
+	if err != nil {
+		return nil, err
+	}
+	explicit := len(names) > 0
+	if !explicit {
+		for _, name := range pkg.Scope().Names() {
+			names = append(names, name)
+		}
+	}
+	g := &generator{
+		pkg:     pkg,
+		imports: map[string]string{},
+	}
+	for _, name := range names {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			if explicit {
+				return nil, fmt.Errorf("type %s not found", name)
+			}
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		if _, ok := named.Underlying().(*types.Struct); !ok || named.TypeParams().Len() > 0 || !mayContainStringx(named, map[types.Type]bool{}) {
+			if explicit {
+				return nil, fmt.Errorf("type %s is not a non generic struct with Stringx fields", name)
+			}
+			continue
+		}
+		if err := g.generateType(named); err != nil {
+			if explicit {
+				return nil, err
+			}
+			fmt.Fprintf(warnings, "cryptox-gen: skipping %s: %v\n", name, err)
+		}
+	}
+	if g.body.Len() == 0 {
+		return nil, errors.New("no types to generate")
+	}
+	return g.source()
+}
+
+func loadPackage(dir, output string) (*types.Package, error) {
+	buildPkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range buildPkg.GoFiles {
+		if name == output {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	config := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	return config.Check(buildPkg.ImportPath, fset, files, nil)
+}
+
+type generator struct {
+	pkg     *types.Package
+	imports map[string]string
+	body    bytes.Buffer
+	// code and typeImports hold the statements and imports of the type being generated
+	code        bytes.Buffer
+	typeImports map[string]string
+	// expanding holds the named types being expanded to detect recursive types
+	expanding []*types.Named
+	depth     int
+}
+
+func (g *generator) generateType(named *types.Named) error {
+	g.code.Reset()
+	g.typeImports = map[string]string{cryptoxPath: "cryptox", "errors": "errors"}
+	g.expanding = nil
+	g.depth = 0
+	if err := g.visit(named, "v", nil); err != nil {
+		return err
+	}
+	name := named.Obj().Name()
+	fmt.Fprintf(&g.body, `
+// EncryptFields implements cryptox.Encryptable.
+func (v *%[1]s) EncryptFields(c cryptox.Crypto) error {
+	return v.cryptoxVisit(c, c.EncryptStringx)
+}
+
+// DecryptFields implements cryptox.Encryptable.
+func (v *%[1]s) DecryptFields(c cryptox.Crypto) error {
+	return v.cryptoxVisit(c, c.DecryptStringx)
+}
+
+func (v *%[1]s) cryptoxVisit(c cryptox.Crypto, visit func(path string, stringx *cryptox.Stringx) error) error {
+	if v == nil {
+		return errors.New("invalid value - pointer is nil")
+	}
+%[2]s	return nil
+}
+`, name, g.code.String())
+	for path, name := range g.typeImports {
+		g.imports[path] = name
+	}
+	return nil
+}
+
+// visit writes the statements visiting every Stringx in expr, which is an addressable expression of type t.
+func (g *generator) visit(t types.Type, expr string, path pathExpr) error {
+	if !mayContainStringx(t, map[types.Type]bool{}) {
+		return nil
+	}
+	if isStringx(t) {
+		fmt.Fprintf(&g.code, "if err := visit(%s, &%s); err != nil {\nreturn err\n}\n", path, expr)
+		return nil
+	}
+	if named, ok := t.(*types.Named); ok {
+		for _, expanding := range g.expanding {
+			if types.Identical(expanding, named) {
+				return fmt.Errorf("%s is a recursive type", named.Obj().Name())
+			}
+		}
+		g.expanding = append(g.expanding, named)
+		defer func() { g.expanding = g.expanding[:len(g.expanding)-1] }()
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		if isStringx(u.Elem()) {
+			// a nil *Stringx is replaced by an empty Stringx like the reflection walk does
+			fmt.Fprintf(&g.code, "if %[1]s == nil {\n%[1]s = &cryptox.Stringx{}\n}\n", expr)
+			fmt.Fprintf(&g.code, "if err := visit(%s, %s); err != nil {\nreturn err\n}\n", path, expr)
+			return nil
+		}
+		fmt.Fprintf(&g.code, "if %s != nil {\n", expr)
+		elem := "(*" + expr + ")"
+		if _, ok := u.Elem().Underlying().(*types.Struct); ok {
+			// fields are selected through the pointer
+			elem = expr
+		}
+		if err := g.visit(u.Elem(), elem, path); err != nil {
+			return err
+		}
+		g.code.WriteString("}\n")
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			field := u.Field(i)
+			if !mayContainStringx(field.Type(), map[types.Type]bool{}) {
+				continue
+			}
+			embeddedStruct := false
+			if field.Embedded() {
+				fieldType := field.Type()
+				if pointer, ok := fieldType.Underlying().(*types.Pointer); ok {
+					fieldType = pointer.Elem()
+				}
+				_, embeddedStruct = fieldType.Underlying().(*types.Struct)
+			}
+			if !field.Exported() && !embeddedStruct {
+				// unexported fields are skipped unless the Crypto fails on them like the reflection walk
+				fmt.Fprintf(&g.code, "if err := cryptox.CheckUnexportedField(c, %s); err != nil {\nreturn err\n}\n", path.field(field.Name()))
+				continue
+			}
+			if !field.Exported() && field.Pkg() != g.pkg {
+				return fmt.Errorf("embedded field %s of %s cannot be accessed", field.Name(), field.Pkg().Path())
+			}
+			if err := g.visit(field.Type(), expr+"."+field.Name(), path.field(field.Name())); err != nil {
+				return err
+			}
+		}
+	case *types.Slice:
+		return g.visitIndexed(u.Elem(), expr, path)
+	case *types.Array:
+		return g.visitIndexed(u.Elem(), expr, path)
+	case *types.Map:
+		g.depth++
+		defer func() { g.depth-- }()
+		keys, key, value := fmt.Sprintf("keys%d", g.depth), fmt.Sprintf("key%d", g.depth), fmt.Sprintf("value%d", g.depth)
+		g.typeImports["fmt"] = "fmt"
+		g.typeImports["sort"] = "sort"
+		// keys are sorted like the reflection walk, so both consume randomness in the same order
+		fmt.Fprintf(&g.code, "{\n%[1]s := make([]%[2]s, 0, len(%[3]s))\nfor %[4]s := range %[3]s {\n%[1]s = append(%[1]s, %[4]s)\n}\n", keys, g.typeString(u.Key()), expr, key)
+		fmt.Fprintf(&g.code, "sort.Slice(%[1]s, func(i, j int) bool {\nreturn fmt.Sprint(%[1]s[i]) < fmt.Sprint(%[1]s[j])\n})\n", keys)
+		fmt.Fprintf(&g.code, "for _, %s := range %s {\n%s := %s[%s]\n", key, keys, value, expr, key)
+		if err := g.visit(u.Elem(), value, path.index("fmt.Sprint("+key+")")); err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.code, "%s[%s] = %s\n}\n}\n", expr, key, value)
+	case *types.Interface:
+		return errors.New("fields of interface type can only be encrypted with reflection")
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+	return nil
+}
+
+func (g *generator) visitIndexed(elem types.Type, expr string, path pathExpr) error {
+	g.depth++
+	defer func() { g.depth-- }()
+	index := fmt.Sprintf("i%d", g.depth)
+	g.typeImports["strconv"] = "strconv"
+	fmt.Fprintf(&g.code, "for %s := range %s {\n", index, expr)
+	if err := g.visit(elem, expr+"["+index+"]", path.index("strconv.Itoa("+index+")")); err != nil {
+		return err
+	}
+	g.code.WriteString("}\n")
+	return nil
+}
+
+// typeString returns t as it is written in the generated file and imports the packages it uses.
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string {
+		if pkg == g.pkg {
+			return ""
+		}
+		g.typeImports[pkg.Path()] = pkg.Name()
+		return pkg.Name()
+	})
+}
+
+func (g *generator) source() ([]byte, error) {
+	var src bytes.Buffer
+	src.WriteString("// Code generated by cryptox-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\nimport (\n", g.pkg.Name())
+	// standard library packages are grouped before other packages
+	var std, other []string
+	for path := range g.imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, strconv.Quote(path))
+		} else {
+			std = append(std, strconv.Quote(path))
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	src.WriteString(strings.Join(std, "\n") + "\n\n" + strings.Join(other, "\n") + "\n")
+	src.WriteString(")\n")
+	src.Write(g.body.Bytes())
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return formatted, nil
+}
+
+// pathExpr is the Go expression building the path of a field, held as literal and code parts.
+type pathExpr []pathPart
+
+type pathPart struct {
+	literal string
+	code    string
+}
+
+func (p pathExpr) field(name string) pathExpr {
+	if len(p) == 0 {
+		return pathExpr{{literal: name}}
+	}
+	return p.append(pathPart{literal: "." + name})
+}
+
+func (p pathExpr) index(code string) pathExpr {
+	return p.append(pathPart{literal: "["}, pathPart{code: code}, pathPart{literal: "]"})
+}
+
+func (p pathExpr) append(parts ...pathPart) pathExpr {
+	joined := append(pathExpr{}, p...)
+	for _, part := range parts {
+		if last := len(joined) - 1; part.code == "" && last >= 0 && joined[last].code == "" {
+			joined[last].literal += part.literal
+			continue
+		}
+		joined = append(joined, part)
+	}
+	return joined
+}
+
+func (p pathExpr) String() string {
+	if len(p) == 0 {
+		return `""`
+	}
+	parts := make([]string, len(p))
+	for i, part := range p {
+		if part.code != "" {
+			parts[i] = part.code
+		} else {
+			parts[i] = strconv.Quote(part.literal)
+		}
+	}
+	return strings.Join(parts, " + ")
+}
+
+func isStringx(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Name() == "Stringx" && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == cryptoxPath
+}
+
+// mayContainStringx reports if a value of type t can hold a Stringx, like the function of the same name in cryptox.
+func mayContainStringx(t types.Type, visiting map[types.Type]bool) bool {
+	if isStringx(t) {
+		return true
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Interface:
+		return true
+	case *types.Pointer:
+		return mayContainStringx(u.Elem(), visiting)
+	case *types.Slice:
+		return mayContainStringx(u.Elem(), visiting)
+	case *types.Array:
+		return mayContainStringx(u.Elem(), visiting)
+	case *types.Map:
+		return mayContainStringx(u.Elem(), visiting)
+	case *types.Struct:
+		if visiting[t] {
+			return false
+		}
+		visiting[t] = true
+		for i := 0; i < u.NumFields(); i++ {
+			if mayContainStringx(u.Field(i).Type(), visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
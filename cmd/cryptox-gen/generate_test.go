@@ -0,0 +1,22 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateIsUpToDate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("type checking the dependencies from source is slow")
+	}
+	src, err := generate("internal/example", "cryptox_gen.go", []string{"User"}, io.Discard)
+	assert.NoError(t, err)
+	existing, err := os.ReadFile("internal/example/cryptox_gen.go")
+	assert.NoError(t, err)
+	assert.Equal(t, string(existing), string(src), "run go generate ./cmd/cryptox-gen/...")
+	_, err = generate("internal/example", "cryptox_gen.go", []string{"Missing"}, io.Discard)
+	assert.Error(t, err)
+}
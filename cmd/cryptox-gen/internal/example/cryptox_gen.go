@@ -0,0 +1,127 @@
+// Code generated by cryptox-gen. DO NOT EDIT.
+
+package example
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/nuntiodev/x/cryptox"
+)
+
+// EncryptFields implements cryptox.Encryptable.
+func (v *User) EncryptFields(c cryptox.Crypto) error {
+	return v.cryptoxVisit(c, c.EncryptStringx)
+}
+
+// DecryptFields implements cryptox.Encryptable.
+func (v *User) DecryptFields(c cryptox.Crypto) error {
+	return v.cryptoxVisit(c, c.DecryptStringx)
+}
+
+func (v *User) cryptoxVisit(c cryptox.Crypto, visit func(path string, stringx *cryptox.Stringx) error) error {
+	if v == nil {
+		return errors.New("invalid value - pointer is nil")
+	}
+	if err := visit("audit.Note", &v.audit.Note); err != nil {
+		return err
+	}
+	if err := visit("Name", &v.Name); err != nil {
+		return err
+	}
+	if v.Email == nil {
+		v.Email = &cryptox.Stringx{}
+	}
+	if err := visit("Email", v.Email); err != nil {
+		return err
+	}
+	if err := visit("Address.Street", &v.Address.Street); err != nil {
+		return err
+	}
+	if v.Address.City == nil {
+		v.Address.City = &cryptox.Stringx{}
+	}
+	if err := visit("Address.City", v.Address.City); err != nil {
+		return err
+	}
+	for i1 := range v.Previous {
+		if err := visit("Previous["+strconv.Itoa(i1)+"].Street", &v.Previous[i1].Street); err != nil {
+			return err
+		}
+		if v.Previous[i1].City == nil {
+			v.Previous[i1].City = &cryptox.Stringx{}
+		}
+		if err := visit("Previous["+strconv.Itoa(i1)+"].City", v.Previous[i1].City); err != nil {
+			return err
+		}
+	}
+	for i1 := range v.Documents {
+		if v.Documents[i1] != nil {
+			if err := visit("Documents["+strconv.Itoa(i1)+"].Title", &v.Documents[i1].Title); err != nil {
+				return err
+			}
+			for i2 := range v.Documents[i1].Pages {
+				if err := visit("Documents["+strconv.Itoa(i1)+"].Pages["+strconv.Itoa(i2)+"]", &v.Documents[i1].Pages[i2]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	{
+		keys1 := make([]string, 0, len(v.Phones))
+		for key1 := range v.Phones {
+			keys1 = append(keys1, key1)
+		}
+		sort.Slice(keys1, func(i, j int) bool {
+			return fmt.Sprint(keys1[i]) < fmt.Sprint(keys1[j])
+		})
+		for _, key1 := range keys1 {
+			value1 := v.Phones[key1]
+			if value1 == nil {
+				value1 = &cryptox.Stringx{}
+			}
+			if err := visit("Phones["+fmt.Sprint(key1)+"]", value1); err != nil {
+				return err
+			}
+			v.Phones[key1] = value1
+		}
+	}
+	{
+		keys1 := make([]int, 0, len(v.Labels))
+		for key1 := range v.Labels {
+			keys1 = append(keys1, key1)
+		}
+		sort.Slice(keys1, func(i, j int) bool {
+			return fmt.Sprint(keys1[i]) < fmt.Sprint(keys1[j])
+		})
+		for _, key1 := range keys1 {
+			value1 := v.Labels[key1]
+			if err := visit("Labels["+fmt.Sprint(key1)+"]", &value1); err != nil {
+				return err
+			}
+			v.Labels[key1] = value1
+		}
+	}
+	for i1 := range v.Codes {
+		if err := visit("Codes["+strconv.Itoa(i1)+"]", &v.Codes[i1]); err != nil {
+			return err
+		}
+	}
+	if v.Manager != nil {
+		if err := visit("Manager.Street", &v.Manager.Street); err != nil {
+			return err
+		}
+		if v.Manager.City == nil {
+			v.Manager.City = &cryptox.Stringx{}
+		}
+		if err := visit("Manager.City", v.Manager.City); err != nil {
+			return err
+		}
+	}
+	if err := cryptox.CheckUnexportedField(c, "hidden"); err != nil {
+		return err
+	}
+	return nil
+}
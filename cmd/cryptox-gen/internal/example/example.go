@@ -0,0 +1,39 @@
+// Package example holds types with generated cryptox methods, used to test cryptox-gen.
+package example
+
+import (
+	"time"
+
+	"github.com/nuntiodev/x/cryptox"
+)
+
+//go:generate go run github.com/nuntiodev/x/cmd/cryptox-gen -type User
+
+type Address struct {
+	Street cryptox.Stringx
+	City   *cryptox.Stringx
+}
+
+type Document struct {
+	Title cryptox.Stringx
+	Pages []cryptox.Stringx
+}
+
+type audit struct {
+	Note cryptox.Stringx
+}
+
+type User struct {
+	audit
+	Name      cryptox.Stringx
+	Email     *cryptox.Stringx
+	Address   Address
+	Previous  []Address
+	Documents []*Document
+	Phones    map[string]*cryptox.Stringx
+	Labels    map[int]cryptox.Stringx
+	Codes     [2]cryptox.Stringx
+	Manager   *Address
+	CreatedAt time.Time
+	hidden    cryptox.Stringx
+}
@@ -0,0 +1,94 @@
+package example
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuntiodev/x/cryptox"
+	"github.com/stretchr/testify/assert"
+)
+
+func newUser() User {
+	return User{
+		audit:     audit{Note: cryptox.Stringx{Body: "note"}},
+		Name:      cryptox.Stringx{Body: "name"},
+		Address:   Address{Street: cryptox.Stringx{Body: "street"}},
+		Previous:  []Address{{Street: cryptox.Stringx{Body: "old street"}, City: &cryptox.Stringx{Body: "old city"}}},
+		Documents: []*Document{{Title: cryptox.Stringx{Body: "passport"}, Pages: []cryptox.Stringx{{Body: "one"}, {Body: "two"}}}, nil},
+		Phones:    map[string]*cryptox.Stringx{"work": {Body: "+4512345678"}, "home": nil, "mobile": {Body: "+4587654321"}},
+		Labels:    map[int]cryptox.Stringx{10: {Body: "ten"}, 2: {Body: "two"}},
+		Codes:     [2]cryptox.Stringx{{Body: "a"}, {Body: "b"}},
+		Manager:   &Address{City: &cryptox.Stringx{Body: "manager city"}},
+		CreatedAt: time.Unix(0, 0),
+		hidden:    cryptox.Stringx{Body: "hidden"},
+	}
+}
+
+// newCrypto returns a Crypto with reproducible nonces which records the paths it encrypts.
+func newCrypto(t *testing.T, key string, paths *[]string) cryptox.Crypto {
+	c, err := cryptox.New([]string{key}, nil, nil,
+		cryptox.WithKeyDerivation(cryptox.KeyDerivationHKDF),
+		cryptox.WithRandReader(rand.New(rand.NewSource(1))),
+		cryptox.WithObserver(cryptox.ObserverFuncs{Encrypt: func(event cryptox.Event) {
+			*paths = append(*paths, event.Path)
+		}}),
+	)
+	assert.NoError(t, err)
+	return c
+}
+
+// TestGeneratedMatchesReflection encrypts the same value through the generated methods and through
+// the reflection walk of cryptox and expects identical output.
+func TestGeneratedMatchesReflection(t *testing.T) {
+	key, err := cryptox.GenerateSymmetricKey(32, cryptox.AlphaNum)
+	assert.NoError(t, err)
+	var generatedPaths, reflectedPaths []string
+	generatedCrypto := newCrypto(t, key, &generatedPaths)
+	reflectedCrypto := newCrypto(t, key, &reflectedPaths)
+	generated := newUser()
+	var _ cryptox.Encryptable = &generated
+	// a struct holding the user in a named field does not implement Encryptable and is walked with reflection
+	reflected := &struct{ Value User }{Value: newUser()}
+	assert.NoError(t, generatedCrypto.Encrypt(&generated))
+	assert.NoError(t, reflectedCrypto.Encrypt(reflected))
+	assert.NotEqual(t, newUser(), generated)
+	assert.Equal(t, reflected.Value, generated)
+	for i := range reflectedPaths {
+		reflectedPaths[i] = strings.TrimPrefix(reflectedPaths[i], "Value.")
+	}
+	assert.Equal(t, reflectedPaths, generatedPaths)
+	assert.Contains(t, generatedPaths, "Phones[home]")
+	assert.NoError(t, generatedCrypto.Decrypt(&generated))
+	assert.NoError(t, reflectedCrypto.Decrypt(reflected))
+	assert.Equal(t, reflected.Value, generated)
+	assert.Equal(t, "passport", generated.Documents[0].Title.Body)
+	assert.Equal(t, "hidden", generated.hidden.Body)
+	var nilUser *User
+	assert.Error(t, generatedCrypto.Encrypt(nilUser))
+}
+
+// TestGeneratedUnexportedFields expects the generated methods and the reflection walk to treat unexported
+// fields the same way for both cryptox.UnexportedFields modes.
+func TestGeneratedUnexportedFields(t *testing.T) {
+	key, err := cryptox.GenerateSymmetricKey(32, cryptox.AlphaNum)
+	assert.NoError(t, err)
+	for _, mode := range []cryptox.UnexportedFields{cryptox.SkipUnexported, cryptox.ErrorOnUnexported} {
+		c, err := cryptox.New([]string{key}, nil, nil, cryptox.WithUnexportedFields(mode))
+		assert.NoError(t, err)
+		generated := newUser()
+		reflected := &struct{ Value User }{Value: newUser()}
+		generatedErr := c.Encrypt(&generated)
+		reflectedErr := c.Encrypt(reflected)
+		if mode == cryptox.ErrorOnUnexported {
+			assert.EqualError(t, generatedErr, "unexported field hidden cannot be processed")
+			assert.EqualError(t, reflectedErr, "unexported field Value.hidden cannot be processed")
+		} else {
+			assert.NoError(t, generatedErr)
+			assert.NoError(t, reflectedErr)
+		}
+		assert.Equal(t, "hidden", generated.hidden.Body)
+		assert.NoError(t, c.Close())
+	}
+}
@@ -0,0 +1,46 @@
+// Command cryptox-gen generates EncryptFields and DecryptFields methods for structs with cryptox.Stringx fields,
+// so cryptox.Crypto can encrypt and decrypt them without reflection. Add a directive to the package:
+//
+//	//go:generate go run github.com/nuntiodev/x/cmd/cryptox-gen -type User,Order
+//
+// The generated methods visit the same fields in the same order as the reflection walk of cryptox. Unexported
+// fields are skipped, or fail the operation if the Crypto was created with cryptox.ErrorOnUnexported. Types with
+// interface fields or recursive types cannot be generated, and values shared by several pointers are visited
+// once per pointer, where the reflection walk only visits them once.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	fs := flag.NewFlagSet("cryptox-gen", flag.ExitOnError)
+	typeNames := fs.String("type", "", "comma separated names of the types to generate methods for, all structs with Stringx fields if empty")
+	output := fs.String("output", "cryptox_gen.go", "name of the generated file in the package directory")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cryptox-gen [flags] [package directory]\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[1:])
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+	var names []string
+	if *typeNames != "" {
+		names = strings.Split(*typeNames, ",")
+	}
+	src, err := generate(dir, *output, names, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cryptox-gen: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filepath.Join(dir, *output), src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "cryptox-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
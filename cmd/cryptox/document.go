@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/nuntiodev/x/cryptox"
+)
+
+// stringxField holds the names a Stringx field has when it is marshalled by encoding/json or by the mongo driver.
+type stringxField struct {
+	jsonName string
+	bsonName string
+}
+
+var stringxFields = func() []stringxField {
+	var fields []stringxField
+	t := reflect.TypeOf(cryptox.Stringx{})
+	for i := 0; i < t.NumField(); i++ {
+		jsonName := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if jsonName == "" {
+			jsonName = t.Field(i).Name
+		}
+		// the mongo driver lowercases the field name unless the bson tag sets a name
+		bsonName := strings.Split(t.Field(i).Tag.Get("bson"), ",")[0]
+		if bsonName == "" {
+			bsonName = strings.ToLower(t.Field(i).Name)
+		}
+		fields = append(fields, stringxField{
+			jsonName: jsonName,
+			bsonName: bsonName,
+		})
+	}
+	return fields
+}()
+
+// document is a JSON document where every object shaped like a Stringx is replaced by a *cryptox.Stringx,
+// so it can be passed to the methods of cryptox.Crypto.
+type document struct {
+	Value interface{}
+	// bsonNames holds the Stringx values which used the field names of the mongo driver in the input
+	bsonNames map[*cryptox.Stringx]bool
+}
+
+// readDocuments calls fn for every JSON value in r. Both NDJSON and single JSON documents are accepted.
+func readDocuments(r io.Reader, fn func(doc *document) error) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	for {
+		var raw interface{}
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		doc := &document{
+			bsonNames: map[*cryptox.Stringx]bool{},
+		}
+		var err error
+		if doc.Value, err = doc.fromJSON(raw); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *document) fromJSON(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		stringx, bsonNames, err := toStringx(v)
+		if err != nil {
+			return nil, err
+		}
+		if stringx != nil {
+			d.bsonNames[stringx] = bsonNames
+			return stringx, nil
+		}
+		for key, elem := range v {
+			if v[key], err = d.fromJSON(elem); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, elem := range v {
+			var err error
+			if v[i], err = d.fromJSON(elem); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value, nil
+}
+
+// toStringx returns a Stringx if every key of object is a Stringx field and both the body and encryption level are set.
+func toStringx(object map[string]interface{}) (*cryptox.Stringx, bool, error) {
+	bsonNames := false
+	normalized := map[string]interface{}{}
+	for key, value := range object {
+		known := false
+		for _, field := range stringxFields {
+			if key == field.jsonName || key == field.bsonName {
+				known = true
+				bsonNames = bsonNames || key != field.jsonName
+				normalized[field.jsonName] = value
+			}
+		}
+		if !known {
+			return nil, false, nil
+		}
+	}
+	if _, ok := normalized["body"].(string); !ok {
+		return nil, false, nil
+	}
+	if _, ok := normalized["encryption_level"]; !ok {
+		return nil, false, nil
+	}
+	bytes, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, false, err
+	}
+	stringx := &cryptox.Stringx{}
+	if err := json.Unmarshal(bytes, stringx); err != nil {
+		return nil, false, err
+	}
+	return stringx, bsonNames, nil
+}
+
+func (d *document) toJSON(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case *cryptox.Stringx:
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		object := map[string]interface{}{}
+		if err := json.Unmarshal(bytes, &object); err != nil {
+			return nil, err
+		}
+		if d.bsonNames[v] {
+			for _, field := range stringxFields {
+				if fieldValue, ok := object[field.jsonName]; ok {
+					delete(object, field.jsonName)
+					object[field.bsonName] = fieldValue
+				}
+			}
+		}
+		return object, nil
+	case map[string]interface{}:
+		object := map[string]interface{}{}
+		for key, elem := range v {
+			var err error
+			if object[key], err = d.toJSON(elem); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, elem := range v {
+			var err error
+			if array[i], err = d.toJSON(elem); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	}
+	return value, nil
+}
+
+// write writes the document as a single line of JSON.
+func (d *document) write(w io.Writer) error {
+	value, err := d.toJSON(d.Value)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiodev/x/cryptox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentJWERoundTrip(t *testing.T) {
+	key, err := cryptox.GenerateSymmetricKey(32, cryptox.AlphaNum)
+	assert.NoError(t, err)
+	keysFile := filepath.Join(t.TempDir(), "keys")
+	assert.NoError(t, os.WriteFile(keysFile, []byte(key+"\n"), 0600))
+	// the input uses the field names of the mongo driver
+	input := `{"_id":"user-1","name":{"body":"Jane Doe","encryptionlevel":0,"publickeyencrypted":false}}`
+	var out bytes.Buffer
+	assert.NoError(t, runReencrypt([]string{"-keys-env", "", "-new-keys-file", keysFile, "-new-jwe"}, strings.NewReader(input), &out))
+	assert.Contains(t, out.String(), `"bodyformat":"jwe"`)
+	assert.Contains(t, out.String(), `"encryptionlevel":1`)
+	crypto, err := cryptox.New([]string{key}, nil, nil)
+	assert.NoError(t, err)
+	count := 0
+	assert.NoError(t, readDocuments(&out, func(doc *document) error {
+		count++
+		name := doc.Value.(map[string]interface{})["name"].(*cryptox.Stringx)
+		assert.Equal(t, cryptox.FormatJWE, name.BodyFormat)
+		assert.True(t, doc.bsonNames[name])
+		assert.NoError(t, crypto.Decrypt(name))
+		assert.Equal(t, "Jane Doe", name.Body)
+		return nil
+	}))
+	assert.Equal(t, 1, count)
+}
+
+func TestReadAndWriteDocuments(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		// output is the NDJSON written for the documents without changing them
+		output   string
+		stringxs int
+	}{
+		{
+			name:     "ndjson",
+			input:    "{\"a\":{\"body\":\"x\",\"encryption_level\":0}}\n{\"b\":1}\n",
+			output:   "{\"a\":{\"body\":\"x\",\"encryption_level\":0,\"public_key_encrypted\":false}}\n{\"b\":1}\n",
+			stringxs: 1,
+		},
+		{
+			name:     "single indented document",
+			input:    "{\n  \"a\": {\n    \"body\": \"x\",\n    \"encryption_level\": 1\n  }\n}\n",
+			output:   "{\"a\":{\"body\":\"x\",\"encryption_level\":1,\"public_key_encrypted\":false}}\n",
+			stringxs: 1,
+		},
+		{
+			name:     "nested arrays",
+			input:    `{"list":[{"items":[{"body":"x","encryption_level":0}]},{"body":"y","encryption_level":0}]}`,
+			output:   "{\"list\":[{\"items\":[{\"body\":\"x\",\"encryption_level\":0,\"public_key_encrypted\":false}]},{\"body\":\"y\",\"encryption_level\":0,\"public_key_encrypted\":false}]}\n",
+			stringxs: 2,
+		},
+		{
+			name:     "mongo driver names",
+			input:    `{"a":{"body":"x","encryptionlevel":2,"publickeyencrypted":true,"bodyformat":"jwe"}}`,
+			output:   "{\"a\":{\"body\":\"x\",\"bodyformat\":\"jwe\",\"encryptionlevel\":2,\"publickeyencrypted\":true}}\n",
+			stringxs: 1,
+		},
+		{
+			name:   "objects which only look like a Stringx",
+			input:  `{"a":{"body":"x","encryption_level":0,"other":1},"b":{"body":"x"},"c":{"body":1,"encryption_level":0}}`,
+			output: "{\"a\":{\"body\":\"x\",\"encryption_level\":0,\"other\":1},\"b\":{\"body\":\"x\"},\"c\":{\"body\":1,\"encryption_level\":0}}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			stringxs := 0
+			assert.NoError(t, readDocuments(strings.NewReader(test.input), func(doc *document) error {
+				stringxs += len(doc.bsonNames)
+				return doc.write(&out)
+			}))
+			assert.Equal(t, test.stringxs, stringxs)
+			assert.Equal(t, test.output, out.String())
+		})
+	}
+	assert.Error(t, readDocuments(strings.NewReader(`{"a":`), func(doc *document) error { return nil }))
+}
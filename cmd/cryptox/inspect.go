@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+
+	"github.com/nuntiodev/x/cryptox"
+)
+
+type inspectResult struct {
+	Document    int                   `json:"document"`
+	Coverage    float64               `json:"coverage"`
+	Upgradeable bool                  `json:"upgradeable"`
+	Fields      []cryptox.FieldReport `json:"fields"`
+}
+
+func runInspect(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	keys := &keyFlags{}
+	keys.register(fs, "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// keys are optional - they are only needed to report key ids and if fields are upgradeable
+	crypto, err := keys.crypto(true)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(stdout)
+	count := 0
+	return readDocuments(stdin, func(doc *document) error {
+		report, err := crypto.Inspect(&doc.Value)
+		if err != nil {
+			return err
+		}
+		count++
+		return encoder.Encode(&inspectResult{
+			Document:    count,
+			Coverage:    report.Coverage(),
+			Upgradeable: report.Upgradeable(),
+			Fields:      report.Fields,
+		})
+	})
+}
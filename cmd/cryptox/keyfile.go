@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nuntiodev/x/cryptox"
+)
+
+const keyfileUsage = `usage: cryptox keyfile <create|add-level|passphrase> [flags]
+
+	create      encrypt keys into a new key file, a new symmetric key is generated if no keys are given
+	add-level   add a symmetric key as a new level
+	passphrase  change the passphrase
+`
+
+// passphraseFlags holds the flags used to read a passphrase.
+type passphraseFlags struct {
+	file string
+	env  string
+}
+
+func (p *passphraseFlags) register(fs *flag.FlagSet, prefix string) {
+	flagPrefix, envPrefix := "", "CRYPTOX_"
+	if prefix != "" {
+		flagPrefix = prefix + "-"
+		envPrefix = strings.ToUpper(prefix) + "_" + envPrefix
+	}
+	fs.StringVar(&p.file, flagPrefix+"passphrase-file", "", "file with the passphrase of the key file")
+	fs.StringVar(&p.env, flagPrefix+"passphrase-env", envPrefix+"PASSPHRASE", "environment variable with the passphrase of the key file")
+}
+
+func (p *passphraseFlags) passphrase() ([]byte, error) {
+	secret, err := readSecret(p.file, p.env)
+	if err != nil {
+		return nil, err
+	}
+	passphrase := []byte(strings.TrimRight(secret, "\r\n"))
+	if len(passphrase) == 0 {
+		return nil, errors.New("no passphrase provided")
+	}
+	return passphrase, nil
+}
+
+func runKeyfile(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(keyfileUsage)
+	}
+	switch args[0] {
+	case "create":
+		return runKeyfileCreate(args[1:])
+	case "add-level":
+		return runKeyfileAddLevel(args[1:])
+	case "passphrase":
+		return runKeyfilePassphrase(args[1:])
+	}
+	return fmt.Errorf("unknown keyfile command %q\n\n%s", args[0], keyfileUsage)
+}
+
+func runKeyfileCreate(args []string) error {
+	fs := flag.NewFlagSet("keyfile create", flag.ContinueOnError)
+	file := fs.String("file", "", "path of the key file to create")
+	kdf := fs.String("kdf", string(cryptox.KDFScrypt), "key derivation of the passphrase: scrypt or argon2id")
+	// the passphrase flags of keyFlags set the passphrase of the new key file
+	keys := &keyFlags{}
+	keys.register(fs, "")
+	passphrase := &keys.passphrase
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	if _, err := os.Stat(*file); err == nil {
+		return fmt.Errorf("%s already exists", *file)
+	}
+	var opts []cryptox.KeyFileOption
+	switch cryptox.KeyFileKDF(*kdf) {
+	case cryptox.KDFScrypt:
+	case cryptox.KDFArgon2id:
+		opts = append(opts, cryptox.WithArgon2id(3, 64*1024, 4))
+	default:
+		return fmt.Errorf("unknown kdf %q", *kdf)
+	}
+	secret, err := passphrase.passphrase()
+	if err != nil {
+		return err
+	}
+	keyFileKeys := &cryptox.KeyFileKeys{}
+	defer keyFileKeys.Wipe()
+	symmetricKeys, err := keys.symmetricKeys()
+	if err != nil {
+		return fmt.Errorf("symmetric keys: %w", err)
+	}
+	for _, symmetricKey := range symmetricKeys {
+		key, err := hex.DecodeString(symmetricKey)
+		if err != nil {
+			return fmt.Errorf("symmetric keys: %w", err)
+		}
+		keyFileKeys.SymmetricKeys = append(keyFileKeys.SymmetricKeys, key)
+	}
+	if len(keyFileKeys.SymmetricKeys) == 0 {
+		key, err := generateKey(32)
+		if err != nil {
+			return err
+		}
+		keyFileKeys.SymmetricKeys = append(keyFileKeys.SymmetricKeys, key)
+	}
+	if keyFileKeys.PrivateKey, err = keys.privateKey(); err != nil {
+		return fmt.Errorf("private key: %w", err)
+	}
+	if _, keyFileKeys.X25519PrivateKey, err = keys.x25519Keys(); err != nil {
+		return fmt.Errorf("x25519 key: %w", err)
+	}
+	if keys.keyDerivation != "" {
+		if keyFileKeys.KeyDerivation, err = cryptox.ParseKeyDerivation(keys.keyDerivation); err != nil {
+			return err
+		}
+	}
+	keyFile, err := cryptox.CreateKeyFile(secret, keyFileKeys, opts...)
+	if err != nil {
+		return err
+	}
+	return keyFile.Write(*file)
+}
+
+func runKeyfileAddLevel(args []string) error {
+	fs := flag.NewFlagSet("keyfile add-level", flag.ContinueOnError)
+	file := fs.String("file", "", "path of the key file")
+	key := fs.String("key", "", "hex encoded symmetric key of the new level, generated if empty")
+	passphrase := &passphraseFlags{}
+	passphrase.register(fs, "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	keyFile, err := cryptox.ReadKeyFile(*file)
+	if err != nil {
+		return err
+	}
+	secret, err := passphrase.passphrase()
+	if err != nil {
+		return err
+	}
+	var symmetricKey []byte
+	if *key != "" {
+		symmetricKey, err = hex.DecodeString(*key)
+	} else {
+		symmetricKey, err = generateKey(32)
+	}
+	if err != nil {
+		return err
+	}
+	if err := keyFile.AddLevel(secret, symmetricKey); err != nil {
+		return err
+	}
+	return keyFile.Write(*file)
+}
+
+func runKeyfilePassphrase(args []string) error {
+	fs := flag.NewFlagSet("keyfile passphrase", flag.ContinueOnError)
+	file := fs.String("file", "", "path of the key file")
+	oldPassphrase := &passphraseFlags{}
+	oldPassphrase.register(fs, "")
+	newPassphrase := &passphraseFlags{}
+	newPassphrase.register(fs, "new")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	keyFile, err := cryptox.ReadKeyFile(*file)
+	if err != nil {
+		return err
+	}
+	oldSecret, err := oldPassphrase.passphrase()
+	if err != nil {
+		return err
+	}
+	newSecret, err := newPassphrase.passphrase()
+	if err != nil {
+		return fmt.Errorf("new passphrase: %w", err)
+	}
+	if err := keyFile.ChangePassphrase(oldSecret, newSecret); err != nil {
+		return err
+	}
+	return keyFile.Write(*file)
+}
+
+// generateKey returns a new symmetric key of size bytes read from crypto/rand.
+func generateKey(size int) ([]byte, error) {
+	key := make([]byte, size)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
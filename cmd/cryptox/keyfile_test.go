@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyfile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "keys.json")
+	passphrase := writeFile(t, "passphrase", "first passphrase\n")
+	newPassphrase := writeFile(t, "new-passphrase", "second passphrase\n")
+	_, keys := newKeysFile(t, 1)
+	keysFile := writeFile(t, "keys", keys[0])
+	var encrypted string
+	tests := []struct {
+		name string
+		args []string
+		// level is the encryption level of values encrypted with the key file afterwards, 0 if the command fails
+		level      string
+		passphrase string
+	}{
+		{name: "create from keys", args: []string{"create", "-file", file, "-keys-file", keysFile, "-passphrase-file", passphrase}, level: `"encryption_level":1`, passphrase: passphrase},
+		{name: "create existing file", args: []string{"create", "-file", file, "-passphrase-file", passphrase}},
+		{name: "create without passphrase", args: []string{"create", "-file", filepath.Join(dir, "other.json")}},
+		{name: "add a generated level", args: []string{"add-level", "-file", file, "-passphrase-file", passphrase}, level: `"encryption_level":2`, passphrase: passphrase},
+		{name: "add a short key", args: []string{"add-level", "-file", file, "-passphrase-file", passphrase, "-key", "00ff"}},
+		{name: "add with wrong passphrase", args: []string{"add-level", "-file", file, "-passphrase-file", newPassphrase}},
+		{name: "change passphrase", args: []string{"passphrase", "-file", file, "-passphrase-file", passphrase, "-new-passphrase-file", newPassphrase}, level: `"encryption_level":2`, passphrase: newPassphrase},
+		{name: "unknown command", args: []string{"rotate"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := runCommand(t, "keyfile", test.args, "")
+			if test.level == "" {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			keyFileArgs := []string{"-keyfile", file, "-passphrase-file", test.passphrase}
+			output, err := runCommand(t, "encrypt", append(keyFileArgs, "-value", "secret"), "")
+			assert.NoError(t, err)
+			assert.Contains(t, output, test.level)
+			decrypted, err := runCommand(t, "decrypt", keyFileArgs, output)
+			assert.NoError(t, err)
+			assert.Equal(t, "secret\n", decrypted)
+			// values encrypted before keep decrypting
+			if encrypted != "" {
+				decrypted, err = runCommand(t, "decrypt", keyFileArgs, encrypted)
+				assert.NoError(t, err)
+				assert.Equal(t, "secret\n", decrypted)
+			}
+			encrypted = strings.TrimSpace(output)
+		})
+	}
+}
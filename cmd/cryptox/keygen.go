@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nuntiodev/x/cryptox"
+)
+
+var runeSets = map[string]int{
+	"alphanum":      cryptox.AlphaNum,
+	"alpha":         cryptox.Alpha,
+	"alphalowernum": cryptox.AlphaLowerNum,
+	"alphauppernum": cryptox.AlphaUpperNum,
+	"alphalower":    cryptox.AlphaLower,
+	"alphaupper":    cryptox.AlphaUpper,
+	"numeric":       cryptox.Numeric,
+}
+
+func runKeygen(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	keyType := fs.String("type", "symmetric", "type of key to generate: symmetric, rsa or x25519")
+	length := fs.Int("length", 32, "length in bytes of a symmetric key")
+	runes := fs.String("runes", "", "only use these runes for a symmetric key, which lowers its strength: alphanum, alpha, alphalowernum, alphauppernum, alphalower, alphaupper or numeric (default random bytes)")
+	bits := fs.Int("bits", 4096, "size in bits of an rsa key")
+	out := fs.String("out", "", "write an rsa or x25519 private key to this file and the public key to <out>.pub instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch *keyType {
+	case "symmetric":
+		if *length < 16 {
+			return errors.New("length must be at least 16 bytes")
+		}
+		if *runes == "" {
+			key, err := generateKey(*length)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(stdout, hex.EncodeToString(key))
+			return err
+		}
+		runeSet, ok := runeSets[*runes]
+		if !ok {
+			return fmt.Errorf("unknown runes %q", *runes)
+		}
+		key, err := cryptox.GenerateSymmetricKey(*length, runeSet)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, key)
+		return err
+	case "rsa":
+		privateKey, publicKey, err := cryptox.GenerateRsaKeyPair(*bits)
+		if err != nil {
+			return err
+		}
+		publicPem, err := cryptox.EncodeRsaPublicKeyPEM(publicKey)
+		if err != nil {
+			return err
+		}
+		privatePem := cryptox.EncodeRsaPrivateKeyPEM(privateKey)
+		if *out == "" {
+			_, err = stdout.Write(append(privatePem, publicPem...))
+			return err
+		}
+		if err := os.WriteFile(*out, privatePem, 0600); err != nil {
+			return err
+		}
+		return os.WriteFile(*out+".pub", publicPem, 0644)
+	case "x25519":
+		privateKey, publicKey, err := cryptox.GenerateX25519KeyPair()
+		if err != nil {
+			return err
+		}
+		privateHex := []byte("private:" + hex.EncodeToString(privateKey[:]) + "\n")
+		publicHex := []byte("public:" + publicKey.String() + "\n")
+		if *out == "" {
+			_, err = stdout.Write(append(privateHex, publicHex...))
+			return err
+		}
+		if err := os.WriteFile(*out, privateHex, 0600); err != nil {
+			return err
+		}
+		return os.WriteFile(*out+".pub", publicHex, 0644)
+	}
+	return errors.New("type must be symmetric, rsa or x25519")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/rsa"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nuntiodev/x/cryptox"
+)
+
+// keyFlags holds the flags used to load the keys of a Crypto. A file always takes precedence over an environment variable.
+type keyFlags struct {
+	keysFile       string
+	keysEnv        string
+	publicKeyFile  string
+	publicKeyEnv   string
+	privateKeyFile string
+	privateKeyEnv  string
+	x25519File     string
+	x25519Env      string
+	keyDerivation  string
+	jwe            bool
+	compression    string
+	compressAbove  int
+	keyFile        string
+	passphrase     passphraseFlags
+}
+
+// register adds the key flags to fs. prefix is used for both flag names and default environment variables,
+// eg. "new" gives -new-keys-file and NEW_CRYPTOX_SYMMETRIC_KEYS.
+func (k *keyFlags) register(fs *flag.FlagSet, prefix string) {
+	flagPrefix, envPrefix := "", "CRYPTOX_"
+	if prefix != "" {
+		flagPrefix = prefix + "-"
+		envPrefix = strings.ToUpper(prefix) + "_" + envPrefix
+	}
+	fs.StringVar(&k.keysFile, flagPrefix+"keys-file", "", "file with one hex encoded symmetric key per line, ordered by level")
+	fs.StringVar(&k.keysEnv, flagPrefix+"keys-env", envPrefix+"SYMMETRIC_KEYS", "environment variable with comma separated hex encoded symmetric keys")
+	fs.StringVar(&k.publicKeyFile, flagPrefix+"public-key-file", "", "file with a pem encoded rsa public key")
+	fs.StringVar(&k.publicKeyEnv, flagPrefix+"public-key-env", envPrefix+"PUBLIC_KEY", "environment variable with a pem encoded rsa public key")
+	fs.StringVar(&k.privateKeyFile, flagPrefix+"private-key-file", "", "file with a pem encoded rsa private key")
+	fs.StringVar(&k.privateKeyEnv, flagPrefix+"private-key-env", envPrefix+"PRIVATE_KEY", "environment variable with a pem encoded rsa private key")
+	fs.StringVar(&k.x25519File, flagPrefix+"x25519-key-file", "", "file with a hex encoded x25519 public or private key, selects x25519 for new values")
+	fs.StringVar(&k.x25519Env, flagPrefix+"x25519-key-env", envPrefix+"X25519_KEY", "environment variable with a hex encoded x25519 public or private key")
+	fs.StringVar(&k.keyDerivation, flagPrefix+"key-derivation", "", "derivation of level keys for new values: xor or hkdf-sha256 (default xor or the derivation of the key file)")
+	fs.StringVar(&k.keyFile, flagPrefix+"keyfile", "", "passphrase encrypted key file created with cryptox keyfile, replaces the other key flags")
+	k.passphrase.register(fs, prefix)
+	fs.BoolVar(&k.jwe, flagPrefix+"jwe", false, "encrypt new values as compact jwe")
+	fs.StringVar(&k.compression, flagPrefix+"compression", "", "compress large bodies of new values before encryption: zstd or deflate, leaks the compressed length")
+	fs.IntVar(&k.compressAbove, flagPrefix+"compression-threshold", cryptox.MinCompressionThreshold, "smallest body in bytes which is compressed")
+}
+
+// formatOptions returns the options selecting how new values are written.
+func (k *keyFlags) formatOptions() ([]cryptox.Option, error) {
+	var opts []cryptox.Option
+	if k.jwe {
+		opts = append(opts, cryptox.WithJWE())
+	}
+	if k.compression != "" {
+		compression, err := cryptox.ParseCompression(k.compression)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, cryptox.WithCompression(compression, k.compressAbove))
+	}
+	return opts, nil
+}
+
+func (k *keyFlags) symmetricKeys() ([]string, error) {
+	secret, err := readSecret(k.keysFile, k.keysEnv)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, line := range strings.Split(secret, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, key := range strings.Split(line, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (k *keyFlags) publicKey() (*rsa.PublicKey, error) {
+	secret, err := readSecret(k.publicKeyFile, k.publicKeyEnv)
+	if err != nil || secret == "" {
+		return nil, err
+	}
+	return cryptox.ParseRsaPublicKeyPEM([]byte(secret))
+}
+
+func (k *keyFlags) privateKey() (*rsa.PrivateKey, error) {
+	secret, err := readSecret(k.privateKeyFile, k.privateKeyEnv)
+	if err != nil || secret == "" {
+		return nil, err
+	}
+	return cryptox.ParseRsaPrivateKeyPEM([]byte(secret))
+}
+
+// x25519Keys returns the x25519 key pair. A public key alone can only encrypt, a private key also gives the public key.
+func (k *keyFlags) x25519Keys() (*cryptox.X25519PublicKey, *cryptox.X25519PrivateKey, error) {
+	secret, err := readSecret(k.x25519File, k.x25519Env)
+	if err != nil || strings.TrimSpace(secret) == "" {
+		return nil, nil, err
+	}
+	kind, key := "public", strings.TrimSpace(secret)
+	if parts := strings.SplitN(key, ":", 2); len(parts) == 2 {
+		kind, key = parts[0], parts[1]
+	}
+	switch kind {
+	case "public":
+		publicKey, err := cryptox.ParseX25519PublicKey(key)
+		return publicKey, nil, err
+	case "private":
+		privateKey, err := cryptox.ParseX25519PrivateKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey, err := privateKey.PublicKey()
+		return publicKey, privateKey, err
+	}
+	return nil, nil, fmt.Errorf("unknown x25519 key kind %q", kind)
+}
+
+// crypto creates a Crypto from the keys. Unless allowNoKeys is set at least one key must be provided.
+func (k *keyFlags) crypto(allowNoKeys bool) (cryptox.Crypto, error) {
+	if k.keyFile != "" {
+		return k.cryptoFromKeyFile()
+	}
+	symmetricKeys, err := k.symmetricKeys()
+	if err != nil {
+		return nil, fmt.Errorf("symmetric keys: %w", err)
+	}
+	publicKey, err := k.publicKey()
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+	privateKey, err := k.privateKey()
+	if err != nil {
+		return nil, fmt.Errorf("private key: %w", err)
+	}
+	x25519PublicKey, x25519PrivateKey, err := k.x25519Keys()
+	if err != nil {
+		return nil, fmt.Errorf("x25519 key: %w", err)
+	}
+	if !allowNoKeys && len(symmetricKeys) == 0 && publicKey == nil && privateKey == nil && x25519PublicKey == nil {
+		return nil, errors.New("no keys provided")
+	}
+	if publicKey == nil && privateKey != nil {
+		publicKey = &privateKey.PublicKey
+	}
+	keyDerivation, err := cryptox.ParseKeyDerivation(k.keyDerivation)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := k.formatOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, cryptox.WithKeyDerivation(keyDerivation))
+	if x25519PublicKey != nil {
+		opts = append(opts, cryptox.WithX25519Keys(x25519PublicKey, x25519PrivateKey))
+	}
+	return cryptox.New(symmetricKeys, publicKey, privateKey, opts...)
+}
+
+func (k *keyFlags) cryptoFromKeyFile() (cryptox.Crypto, error) {
+	passphrase, err := k.passphrase.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	opts, err := k.formatOptions()
+	if err != nil {
+		return nil, err
+	}
+	if k.keyDerivation != "" {
+		keyDerivation, err := cryptox.ParseKeyDerivation(k.keyDerivation)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, cryptox.WithKeyDerivation(keyDerivation))
+	}
+	return cryptox.NewFromKeyFile(k.keyFile, passphrase, opts...)
+}
+
+// readSecret returns the content of file if set and otherwise the value of the environment variable env.
+func readSecret(file, env string) (string, error) {
+	if file != "" {
+		bytes, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return string(bytes), nil
+	}
+	if env != "" {
+		return os.Getenv(env), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,70 @@
+// Command cryptox is a tool for operators working with data encrypted by the cryptox package.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const usage = `cryptox is a tool for operators working with cryptox encrypted data.
+
+Usage:
+
+	cryptox <command> [flags]
+
+Commands:
+%s
+Keys are read from files or environment variables, run "cryptox <command> -h" for the flags of a command.
+Documents are read as JSON or NDJSON from stdin and written as NDJSON to stdout.
+`
+
+type command struct {
+	description string
+	run         func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"keygen":    {"generate a symmetric key or an rsa or x25519 key pair", runKeygen},
+	"keyfile":   {"create and update passphrase encrypted key files", runKeyfile},
+	"encrypt":   {"encrypt a single value", runEncrypt},
+	"decrypt":   {"decrypt a single value", runDecrypt},
+	"inspect":   {"report the Stringx fields of documents", runInspect},
+	"mask":      {"replace values of documents by pseudonyms and encrypt them with staging keys", runMask},
+	"reencrypt": {"re-encrypt documents with a new key set", runReencrypt},
+}
+
+func printUsage(w io.Writer) {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := ""
+	for _, name := range names {
+		list += fmt.Sprintf("\t%-10s %s\n", name, commands[name].description)
+	}
+	fmt.Fprintf(w, usage, list)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+			printUsage(os.Stdout)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "cryptox: unknown command %q\n\n", os.Args[1])
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "cryptox %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
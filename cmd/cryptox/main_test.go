@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiodev/x/cryptox"
+	"github.com/stretchr/testify/assert"
+)
+
+// runCommand runs the command name with args and stdin and returns what it wrote to stdout. The default
+// environment variables of the key flags are cleared, so only keys passed in args are used.
+func runCommand(t *testing.T, name string, args []string, stdin string) (string, error) {
+	t.Helper()
+	for _, prefix := range []string{"", "NEW_", "STAGING_"} {
+		for _, env := range []string{"SYMMETRIC_KEYS", "PUBLIC_KEY", "PRIVATE_KEY", "X25519_KEY", "PASSPHRASE"} {
+			t.Setenv(prefix+"CRYPTOX_"+env, "")
+		}
+	}
+	t.Setenv("CRYPTOX_MASK_KEY", "")
+	var stdout bytes.Buffer
+	err := commands[name].run(args, strings.NewReader(stdin), &stdout)
+	return stdout.String(), err
+}
+
+// writeFile writes content to a new file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// newKeysFile returns a keys file with a new symmetric key per level and the keys.
+func newKeysFile(t *testing.T, levels int) (string, []string) {
+	t.Helper()
+	var keys []string
+	for i := 0; i < levels; i++ {
+		key, err := runCommand(t, "keygen", nil, "")
+		assert.NoError(t, err)
+		keys = append(keys, strings.TrimSpace(key))
+	}
+	return writeFile(t, "keys", strings.Join(keys, "\n")+"\n"), keys
+}
+
+// decryptDocuments decrypts the NDJSON output of a command with keys and returns the documents as JSON objects.
+func decryptDocuments(t *testing.T, output string, keys []string) []map[string]interface{} {
+	t.Helper()
+	crypto, err := cryptox.New(keys, nil, nil)
+	assert.NoError(t, err)
+	var documents []map[string]interface{}
+	assert.NoError(t, readDocuments(strings.NewReader(output), func(doc *document) error {
+		if err := crypto.Decrypt(&doc.Value); err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := doc.write(&buf); err != nil {
+			return err
+		}
+		document := map[string]interface{}{}
+		if err := json.Unmarshal(buf.Bytes(), &document); err != nil {
+			return err
+		}
+		documents = append(documents, document)
+		return nil
+	}))
+	return documents
+}
+
+// body returns the body of the Stringx object at key of document.
+func body(document map[string]interface{}, key string) string {
+	stringx, _ := document[key].(map[string]interface{})
+	body, _ := stringx["body"].(string)
+	return body
+}
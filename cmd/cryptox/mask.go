@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nuntiodev/x/cryptox"
+)
+
+// maskFields collects the repeated -field flags.
+type maskFields []cryptox.MaskOption
+
+func (f *maskFields) String() string {
+	return ""
+}
+
+func (f *maskFields) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("invalid field %q, expected name=kind", value)
+	}
+	kind, err := cryptox.ParseMaskKind(parts[1])
+	if err != nil {
+		return err
+	}
+	*f = append(*f, cryptox.WithMaskField(parts[0], kind))
+	return nil
+}
+
+func runMask(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("mask", flag.ContinueOnError)
+	keys, stagingKeys := &keyFlags{}, &keyFlags{}
+	keys.register(fs, "")
+	stagingKeys.register(fs, "staging")
+	maskKeyFile := fs.String("mask-key-file", "", "file with the hex encoded key pseudonyms are derived from, keep it to get the same pseudonyms in every copy")
+	maskKeyEnv := fs.String("mask-key-env", "CRYPTOX_MASK_KEY", "environment variable with the hex encoded mask key")
+	var fields maskFields
+	fs.Var(&fields, "field", "mask the fields with this path or name as text, email, phone or keep instead of detecting the kind, eg. -field email=email (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	secret, err := readSecret(*maskKeyFile, *maskKeyEnv)
+	if err != nil {
+		return fmt.Errorf("mask key: %w", err)
+	}
+	if strings.TrimSpace(secret) == "" {
+		return errors.New("no mask key provided")
+	}
+	maskKey, err := hex.DecodeString(strings.TrimSpace(secret))
+	if err != nil {
+		return fmt.Errorf("mask key: %w", err)
+	}
+	masker, err := cryptox.NewMasker(maskKey, fields...)
+	if err != nil {
+		return err
+	}
+	// keys are optional so plaintext dumps can be masked
+	crypto, err := keys.crypto(true)
+	if err != nil {
+		return fmt.Errorf("keys: %w", err)
+	}
+	stagingCrypto, err := stagingKeys.crypto(false)
+	if err != nil {
+		return fmt.Errorf("staging keys: %w", err)
+	}
+	count := 0
+	if err := readDocuments(stdin, func(doc *document) error {
+		count++
+		if err := masker.Pseudonymize(crypto, stagingCrypto, &doc.Value); err != nil {
+			return fmt.Errorf("document %d: %w", count, err)
+		}
+		return doc.write(stdout)
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "masked %d documents\n", count)
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMask(t *testing.T) {
+	keysFile, _ := newKeysFile(t, 1)
+	stagingKeysFile, stagingKeys := newKeysFile(t, 1)
+	maskKeyFile := writeFile(t, "mask-key", strings.Repeat("ab", 16)+"\n")
+	shortMaskKeyFile := writeFile(t, "short-mask-key", "abab\n")
+	plain := "{\"_id\":\"1\",\"email\":{\"body\":\"jane@mail.com\",\"encryption_level\":0},\"name\":{\"body\":\"Jane\",\"encryption_level\":0}}\n" +
+		"{\"_id\":\"2\",\"email\":{\"body\":\"Jane@mail.com\",\"encryption_level\":0},\"name\":{\"body\":\"jane@mail.com\",\"encryption_level\":0}}\n"
+	encrypted, err := runCommand(t, "reencrypt", []string{"-new-keys-file", keysFile}, plain)
+	assert.NoError(t, err)
+	tests := []struct {
+		name  string
+		args  []string
+		input string
+		// nameKept is set when name is masked as keep
+		nameKept bool
+		err      bool
+	}{
+		{name: "encrypted documents", args: []string{"-keys-file", keysFile, "-staging-keys-file", stagingKeysFile, "-mask-key-file", maskKeyFile}, input: encrypted},
+		{name: "plaintext documents", args: []string{"-staging-keys-file", stagingKeysFile, "-mask-key-file", maskKeyFile}, input: plain},
+		{name: "field rules", args: []string{"-keys-file", keysFile, "-staging-keys-file", stagingKeysFile, "-mask-key-file", maskKeyFile, "-field", "email=email", "-field", "name=keep"}, input: encrypted, nameKept: true},
+		{name: "wrong keys", args: []string{"-keys-file", stagingKeysFile, "-staging-keys-file", stagingKeysFile, "-mask-key-file", maskKeyFile}, input: encrypted, err: true},
+		{name: "no staging keys", args: []string{"-keys-file", keysFile, "-mask-key-file", maskKeyFile}, input: encrypted, err: true},
+		{name: "no mask key", args: []string{"-keys-file", keysFile, "-staging-keys-file", stagingKeysFile}, input: encrypted, err: true},
+		{name: "short mask key", args: []string{"-keys-file", keysFile, "-staging-keys-file", stagingKeysFile, "-mask-key-file", shortMaskKeyFile}, input: encrypted, err: true},
+		{name: "invalid field", args: []string{"-staging-keys-file", stagingKeysFile, "-mask-key-file", maskKeyFile, "-field", "email"}, input: plain, err: true},
+		{name: "unknown kind", args: []string{"-staging-keys-file", stagingKeysFile, "-mask-key-file", maskKeyFile, "-field", "email=address"}, input: plain, err: true},
+	}
+	var pseudonym string
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := runCommand(t, "mask", test.args, test.input)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			documents := decryptDocuments(t, output, stagingKeys)
+			assert.Len(t, documents, 2)
+			first, second := body(documents[0], "email"), body(documents[1], "email")
+			assert.True(t, strings.HasSuffix(first, ".example"), first)
+			assert.NotEqual(t, "jane@mail.com", first)
+			// emails are case insensitive and the same mask key gives the same pseudonyms
+			assert.Equal(t, first, second)
+			if pseudonym == "" {
+				pseudonym = first
+			}
+			assert.Equal(t, pseudonym, first)
+			if test.nameKept {
+				assert.Equal(t, "Jane", body(documents[0], "name"))
+				assert.Equal(t, "jane@mail.com", body(documents[1], "name"))
+				return
+			}
+			assert.NotEqual(t, "Jane", body(documents[0], "name"))
+			assert.Equal(t, first, body(documents[1], "name"))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func runReencrypt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("reencrypt", flag.ContinueOnError)
+	oldKeys, newKeys := &keyFlags{}, &keyFlags{}
+	oldKeys.register(fs, "")
+	newKeys.register(fs, "new")
+	all := fs.Bool("all", false, "re-encrypt every document and not only those Upgradeble reports, needed when keys are replaced rather than added or extended")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// old keys are optional so plaintext dumps can be encrypted
+	oldCrypto, err := oldKeys.crypto(true)
+	if err != nil {
+		return fmt.Errorf("old keys: %w", err)
+	}
+	newCrypto, err := newKeys.crypto(false)
+	if err != nil {
+		return fmt.Errorf("new keys: %w", err)
+	}
+	count, reencrypted := 0, 0
+	if err := readDocuments(stdin, func(doc *document) error {
+		count++
+		upgradable, err := newCrypto.Upgradeble(&doc.Value)
+		if err != nil {
+			return fmt.Errorf("document %d: %w", count, err)
+		}
+		if upgradable || *all {
+			if err := oldCrypto.Decrypt(&doc.Value); err != nil {
+				return fmt.Errorf("document %d: %w", count, err)
+			}
+			if err := newCrypto.Encrypt(&doc.Value); err != nil {
+				return fmt.Errorf("document %d: %w", count, err)
+			}
+			reencrypted++
+		}
+		return doc.write(stdout)
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "re-encrypted %d of %d documents\n", reencrypted, count)
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReencrypt(t *testing.T) {
+	keysFile, keys := newKeysFile(t, 1)
+	otherKeysFile, otherKeys := newKeysFile(t, 1)
+	extendedKeysFile := writeFile(t, "extended", keys[0]+"\n"+otherKeys[0]+"\n")
+	plain := "{\"_id\":\"1\",\"name\":{\"body\":\"Jane\",\"encryption_level\":0}}\n{\"_id\":\"2\",\"name\":{\"body\":\"John\",\"encryption_level\":0}}\n"
+	encrypted, err := runCommand(t, "reencrypt", []string{"-new-keys-file", keysFile}, plain)
+	assert.NoError(t, err)
+	tests := []struct {
+		name    string
+		args    []string
+		input   string
+		newKeys []string
+		// unchanged is set when the documents must be written as they were read
+		unchanged bool
+		err       bool
+	}{
+		{name: "encrypt plaintext", args: []string{"-new-keys-file", keysFile}, input: plain, newKeys: keys},
+		{name: "add a level", args: []string{"-keys-file", keysFile, "-new-keys-file", extendedKeysFile}, input: encrypted, newKeys: append(keys, otherKeys...)},
+		{name: "same keys", args: []string{"-keys-file", keysFile, "-new-keys-file", keysFile}, input: encrypted, newKeys: keys, unchanged: true},
+		{name: "replaced keys need all", args: []string{"-keys-file", keysFile, "-new-keys-file", otherKeysFile}, input: encrypted, newKeys: keys, unchanged: true},
+		{name: "replace keys", args: []string{"-keys-file", keysFile, "-new-keys-file", otherKeysFile, "-all"}, input: encrypted, newKeys: otherKeys},
+		{name: "wrong old keys", args: []string{"-keys-file", otherKeysFile, "-new-keys-file", extendedKeysFile}, input: encrypted, err: true},
+		{name: "no new keys", args: []string{"-keys-file", keysFile}, input: encrypted, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := runCommand(t, "reencrypt", test.args, test.input)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if test.unchanged {
+				assert.Equal(t, test.input, output)
+			}
+			assert.NotContains(t, output, "Jane")
+			documents := decryptDocuments(t, output, test.newKeys)
+			assert.Len(t, documents, 2)
+			assert.Equal(t, "Jane", body(documents[0], "name"))
+			assert.Equal(t, "John", body(documents[1], "name"))
+			assert.Equal(t, "1", documents[0]["_id"])
+		})
+	}
+}
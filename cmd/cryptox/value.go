@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nuntiodev/x/cryptox"
+)
+
+// readValue returns value if set and otherwise everything on stdin without the trailing newline.
+func readValue(value string, stdin io.Reader) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+	bytes, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(bytes), "\r\n"), nil
+}
+
+func runEncrypt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("encrypt", flag.ContinueOnError)
+	keys := &keyFlags{}
+	keys.register(fs, "")
+	value := fs.String("value", "", "plaintext to encrypt, read from stdin if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	crypto, err := keys.crypto(false)
+	if err != nil {
+		return err
+	}
+	plaintext, err := readValue(*value, stdin)
+	if err != nil {
+		return err
+	}
+	stringx := &cryptox.Stringx{Body: plaintext}
+	if err := crypto.Encrypt(stringx); err != nil {
+		return err
+	}
+	return json.NewEncoder(stdout).Encode(stringx)
+}
+
+func runDecrypt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keys := &keyFlags{}
+	keys.register(fs, "")
+	value := fs.String("value", "", "Stringx JSON to decrypt, read from stdin if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	crypto, err := keys.crypto(false)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := readValue(*value, stdin)
+	if err != nil {
+		return err
+	}
+	stringx := &cryptox.Stringx{}
+	if err := json.Unmarshal([]byte(ciphertext), stringx); err != nil {
+		return err
+	}
+	if err := crypto.Decrypt(stringx); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, stringx.Body)
+	return err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptDecryptValue(t *testing.T) {
+	keysFile, _ := newKeysFile(t, 2)
+	otherKeysFile, _ := newKeysFile(t, 2)
+	x25519Key, err := runCommand(t, "keygen", []string{"-type", "x25519"}, "")
+	assert.NoError(t, err)
+	x25519File := writeFile(t, "x25519", strings.SplitN(x25519Key, "\n", 2)[0])
+	long := strings.Repeat("a value which compresses well. ", 50)
+	tests := []struct {
+		name  string
+		args  []string
+		value string
+		// output is checked against the encrypted Stringx
+		output string
+	}{
+		{name: "symmetric keys", args: []string{"-keys-file", keysFile}, value: "secret", output: `"encryption_level":2`},
+		{name: "jwe", args: []string{"-keys-file", keysFile, "-jwe"}, value: "secret", output: `"format":"jwe"`},
+		{name: "hkdf", args: []string{"-keys-file", keysFile, "-key-derivation", "hkdf-sha256"}, value: "secret", output: `"key_derivation":"hkdf-sha256"`},
+		{name: "compression", args: []string{"-keys-file", keysFile, "-compression", "zstd"}, value: long, output: `"compression":"zstd"`},
+		{name: "x25519", args: []string{"-keys-file", keysFile, "-x25519-key-file", x25519File}, value: "secret", output: `"public_key_encrypted":true`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the value is read from stdin
+			encrypted, err := runCommand(t, "encrypt", test.args, test.value+"\n")
+			assert.NoError(t, err)
+			assert.Contains(t, encrypted, test.output)
+			assert.NotContains(t, encrypted, test.value)
+			decrypted, err := runCommand(t, "decrypt", append(test.args, "-value", strings.TrimSpace(encrypted)), "")
+			assert.NoError(t, err)
+			assert.Equal(t, test.value+"\n", decrypted)
+			// other keys fail
+			_, err = runCommand(t, "decrypt", []string{"-keys-file", otherKeysFile}, encrypted)
+			assert.Error(t, err)
+		})
+	}
+	_, err = runCommand(t, "encrypt", nil, "secret")
+	assert.Error(t, err)
+	_, err = runCommand(t, "decrypt", []string{"-keys-file", keysFile}, "not json")
+	assert.Error(t, err)
+}
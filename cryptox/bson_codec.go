@@ -0,0 +1,68 @@
+package cryptox
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+)
+
+// storedStringx has the fields of Stringx without its codec, so it is encoded by the default struct codec
+// exactly like a Stringx stored without NewBSONRegistry.
+type storedStringx Stringx
+
+var storedStringxType = reflect.TypeOf(storedStringx{})
+
+// NewBSONRegistry returns a registry which encrypts every Stringx and *Stringx with crypto when it is marshalled
+// and decrypts it when it is unmarshalled. Install it on a client with options.Client().SetRegistry, see
+// mongo_client.CreateMongoClient. The value passed to the driver is never changed and values are always
+// encrypted on marshal, so repositories using the registry must stop calling Encrypt and Decrypt themselves.
+//
+// The registry applies to every Stringx the client encodes, also in filters and updates. Encryption uses a new
+// nonce every time, so a filter matching a Stringx value never finds a stored document, query by other fields
+// instead. A Stringx which is already encrypted, eg. the wrapped key of a subject, would be encrypted once more,
+// so the Mongo stores of this package encode their documents with the default registry and can share the client.
+func NewBSONRegistry(crypto Crypto) *bsoncodec.Registry {
+	return RegisterBSONCodecs(bson.NewRegistryBuilder(), crypto).Build()
+}
+
+// RegisterBSONCodecs registers the Stringx codecs of NewBSONRegistry on an existing builder.
+// Pointers to Stringx are handled by the pointer codec of the builder.
+func RegisterBSONCodecs(builder *bsoncodec.RegistryBuilder, crypto Crypto) *bsoncodec.RegistryBuilder {
+	return builder.
+		RegisterTypeEncoder(stringxType, bsoncodec.ValueEncoderFunc(func(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+			if !val.IsValid() || val.Type() != stringxType {
+				return bsoncodec.ValueEncoderError{Name: "StringxEncodeValue", Types: []reflect.Type{stringxType}, Received: val}
+			}
+			stringx := val.Interface().(Stringx)
+			if err := crypto.EncryptStringx("", &stringx); err != nil {
+				return fmt.Errorf("encrypt stringx: %w", err)
+			}
+			encoder, err := ec.LookupEncoder(storedStringxType)
+			if err != nil {
+				return err
+			}
+			return encoder.EncodeValue(ec, vw, reflect.ValueOf(storedStringx(stringx)))
+		})).
+		RegisterTypeDecoder(stringxType, bsoncodec.ValueDecoderFunc(func(dc bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+			if !val.CanSet() || val.Type() != stringxType {
+				return bsoncodec.ValueDecoderError{Name: "StringxDecodeValue", Types: []reflect.Type{stringxType}, Received: val}
+			}
+			decoder, err := dc.LookupDecoder(storedStringxType)
+			if err != nil {
+				return err
+			}
+			stored := reflect.New(storedStringxType).Elem()
+			if err := decoder.DecodeValue(dc, vr, stored); err != nil {
+				return err
+			}
+			stringx := Stringx(stored.Interface().(storedStringx))
+			if err := crypto.DecryptStringx("", &stringx); err != nil {
+				return fmt.Errorf("decrypt stringx: %w", err)
+			}
+			val.Set(reflect.ValueOf(stringx))
+			return nil
+		}))
+}
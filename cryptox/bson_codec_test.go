@@ -0,0 +1,67 @@
+package cryptox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type bsonUser struct {
+	ID      string   `bson:"_id"`
+	Email   Stringx  `bson:"email"`
+	Address *Stringx `bson:"address"`
+	Missing *Stringx `bson:"missing"`
+}
+
+func TestBSONRegistry(t *testing.T) {
+	c := newTestCrypto(t)
+	registry := NewBSONRegistry(c)
+	user := &bsonUser{
+		ID:      "user-1",
+		Email:   Stringx{Body: "test@example.com"},
+		Address: &Stringx{Body: "street 1"},
+	}
+	data, err := bson.MarshalWithRegistry(registry, user)
+	assert.NoError(t, err)
+	// the value given to the driver is unchanged and the stored document holds ciphertext
+	assert.Equal(t, "test@example.com", user.Email.Body)
+	assert.NotContains(t, string(data), "test@example.com")
+	assert.NotContains(t, string(data), "street 1")
+	// the stored document has the layout of a Stringx encrypted with Encrypt
+	var stored struct {
+		Email   Stringx  `bson:"email"`
+		Address *Stringx `bson:"address"`
+		Missing *Stringx `bson:"missing"`
+	}
+	assert.NoError(t, bson.Unmarshal(data, &stored))
+	assert.Equal(t, int32(1), stored.Email.EncryptionLevel)
+	assert.Nil(t, stored.Missing)
+	assert.NoError(t, c.Decrypt(&stored))
+	assert.Equal(t, "test@example.com", stored.Email.Body)
+	decoded := &bsonUser{}
+	assert.NoError(t, bson.UnmarshalWithRegistry(registry, data, decoded))
+	assert.Equal(t, "user-1", decoded.ID)
+	assert.Equal(t, "test@example.com", decoded.Email.Body)
+	assert.Equal(t, "street 1", decoded.Address.Body)
+	assert.Nil(t, decoded.Missing)
+	// values stored with another key fail to decode
+	assert.Error(t, bson.UnmarshalWithRegistry(NewBSONRegistry(newTestCrypto(t)), data, decoded))
+	// filters are encrypted with a new nonce as well, so they never match the stored value
+	filter, err := bson.MarshalWithRegistry(registry, bson.M{"email": user.Email})
+	assert.NoError(t, err)
+	assert.NotEqual(t, bson.Raw(data).Lookup("email"), bson.Raw(filter).Lookup("email"))
+	// the registry would encrypt wrapped keys once more, so the stores of this package use the default registry
+	wrappedKey := &Stringx{Body: "wrapped"}
+	assert.NoError(t, c.Encrypt(wrappedKey))
+	withRegistry, err := bson.MarshalWithRegistry(registry, &subjectKeyDocument{SubjectID: "user-1", Key: wrappedKey})
+	assert.NoError(t, err)
+	document := &subjectKeyDocument{}
+	assert.NoError(t, bson.Unmarshal(withRegistry, document))
+	assert.NotEqual(t, wrappedKey.Body, document.Key.Body)
+	withDefault, err := bson.Marshal(&subjectKeyDocument{SubjectID: "user-1", Key: wrappedKey})
+	assert.NoError(t, err)
+	document = &subjectKeyDocument{}
+	assert.NoError(t, bson.Unmarshal(withDefault, document))
+	assert.Equal(t, wrappedKey, document.Key)
+}
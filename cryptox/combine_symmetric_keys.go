@@ -6,43 +6,53 @@ import (
 	"fmt"
 )
 
+// CombineSymmetricSymmetricKeys builds the key of a level by XOR'ing the first level keys.
+// It is kept so values encrypted with KeyDerivationXOR stay readable - new data should use KeyDerivationHKDF.
 func CombineSymmetricSymmetricKeys(SymmetricKeys []string, level int) (string, error) {
-	if len(SymmetricKeys) == 0 {
-		return "", errors.New("invalid number of SymmetricKeys 0")
-	} else if level > len(SymmetricKeys) {
-		return "", errors.New("level cannot be larger than amount of encryption SymmetricKeys")
-	} else if level <= 0 {
-		return "", errors.New("level cannot be less than 0")
-	}
-	if len(SymmetricKeys) == 1 && level == 1 {
-		return SymmetricKeys[0], nil
+	keys, err := decodeSymmetricKeys(SymmetricKeys)
+	if err != nil {
+		return "", err
 	}
-	// validate length is the same
-	key, err := hex.DecodeString(SymmetricKeys[0])
+	defer setZeroKeys(keys)
+	key, err := xorLevelKey(keys, level)
 	if err != nil {
 		return "", err
 	}
-	initialKey := string(key)
+	defer setZero(key)
+	return hex.EncodeToString(key), nil
+}
+
+func xorLevelKey(symmetricKeys [][]byte, level int) ([]byte, error) {
+	if len(symmetricKeys) == 0 {
+		return nil, errors.New("invalid number of SymmetricKeys 0")
+	} else if level > len(symmetricKeys) {
+		return nil, errors.New("level cannot be larger than amount of encryption SymmetricKeys")
+	} else if level <= 0 {
+		return nil, errors.New("level cannot be less than 0")
+	}
+	key := append([]byte(nil), symmetricKeys[0]...)
+	if len(symmetricKeys) == 1 && level == 1 {
+		return key, nil
+	}
+	// validate length is the same
 	for i := 1; i < level; i++ {
-		if len(SymmetricKeys[i]) != 64 {
-			return "", fmt.Errorf("invalid key size: %d", len(SymmetricKeys[i]))
+		if len(symmetricKeys[i]) != levelKeySize {
+			setZero(key)
+			return nil, fmt.Errorf("invalid key size: %d", len(symmetricKeys[i])*2)
 		}
-		key, err := hex.DecodeString(SymmetricKeys[i])
-		if err != nil {
-			return "", err
+		if len(symmetricKeys[i]) != len(key) {
+			setZero(key)
+			return nil, fmt.Errorf("invalid key size: %d", len(key)*2)
 		}
-		currentKey := string(key)
-		newKey := []byte{}
-		for j := range initialKey {
-			newKey = append(newKey, initialKey[j]^currentKey[j])
+		for j := range key {
+			key[j] ^= symmetricKeys[i][j]
 		}
-		initialKey = string(newKey)
 	}
-	newKey := hex.EncodeToString([]byte(initialKey))
-	if len(newKey) != 64 {
-		return "", fmt.Errorf("invalid length: %d", len(newKey))
+	if len(key) != levelKeySize {
+		setZero(key)
+		return nil, fmt.Errorf("invalid length: %d", len(key)*2)
 	}
-	return newKey, nil
+	return key, nil
 }
 
 // todo: make some distribution over SymmetricKeys to validate randomness
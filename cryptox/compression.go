@@ -0,0 +1,152 @@
+package cryptox
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the algorithm used to compress bodies before they are encrypted, see WithCompression.
+//
+// Compressing before encrypting makes the length of the ciphertext depend on the content of the plaintext.
+// If an attacker can get their own input encrypted next to a secret in the same body, they can learn the
+// secret from the ciphertext lengths (CRIME, BREACH). Only enable compression for fields whose bodies are
+// large and never mix attacker controlled input with secrets, such as notes or JSON documents. Short secrets
+// are never compressed as bodies below MinCompressionThreshold are stored uncompressed.
+type Compression string
+
+const (
+	CompressionZstd    Compression = "zstd"
+	CompressionDeflate Compression = "deflate"
+	// MinCompressionThreshold is the smallest body size in bytes that is compressed
+	MinCompressionThreshold = 256
+	// maxDecompressedSize bounds decompression so a forged body cannot exhaust memory
+	maxDecompressedSize = 64 << 20
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// WithCompression compresses bodies of at least threshold bytes with algorithm before they are encrypted.
+// A threshold below MinCompressionThreshold is raised to it. Bodies are stored uncompressed when compression
+// does not make them smaller. Decrypt decompresses bodies regardless of this option.
+func WithCompression(algorithm Compression, threshold int) Option {
+	return func(c *defaultCrypto) {
+		if threshold < MinCompressionThreshold {
+			threshold = MinCompressionThreshold
+		}
+		c.compression = algorithm
+		c.compressionThreshold = threshold
+	}
+}
+
+// ParseCompression parses the name of a compression algorithm.
+func ParseCompression(name string) (Compression, error) {
+	switch Compression(name) {
+	case CompressionZstd:
+		return CompressionZstd, nil
+	case CompressionDeflate:
+		return CompressionDeflate, nil
+	}
+	return "", fmt.Errorf("unknown compression %q", name)
+}
+
+// compressStringx compresses the body of stringx if it is large enough and compression is enabled.
+func (c *defaultCrypto) compressStringx(stringx *Stringx) error {
+	stringx.Compression = ""
+	if c.compression == "" || len(stringx.Body) < c.compressionThreshold {
+		return nil
+	}
+	compressed, err := compress(c.compression, []byte(stringx.Body))
+	if err != nil {
+		return err
+	}
+	if len(compressed) >= len(stringx.Body) {
+		return nil
+	}
+	stringx.Body = string(compressed)
+	stringx.Compression = string(c.compression)
+	return nil
+}
+
+// decompressStringx restores the body of stringx once every encryption layer has been removed.
+func decompressStringx(stringx *Stringx) error {
+	if stringx.Compression == "" {
+		return nil
+	}
+	decompressed, err := decompress(Compression(stringx.Compression), []byte(stringx.Body))
+	if err != nil {
+		return err
+	}
+	stringx.Body = string(decompressed)
+	stringx.Compression = ""
+	return nil
+}
+
+func compress(algorithm Compression, data []byte) ([]byte, error) {
+	switch algorithm {
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdEncoder.EncodeAll(data, nil), nil
+	case CompressionDeflate:
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown compression %q", algorithm)
+}
+
+func decompress(algorithm Compression, data []byte) ([]byte, error) {
+	var decompressed []byte
+	var err error
+	switch algorithm {
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		decompressed, err = zstdDecoder.DecodeAll(data, nil)
+	case CompressionDeflate:
+		r := flate.NewReader(bytes.NewReader(data))
+		decompressed, err = io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+		r.Close()
+	default:
+		return nil, fmt.Errorf("%w: unknown compression %q", ErrMalformedCiphertext, algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedCiphertext, err)
+	}
+	if len(decompressed) > maxDecompressedSize {
+		return nil, fmt.Errorf("%w: decompressed body is too large", ErrMalformedCiphertext)
+	}
+	return decompressed, nil
+}
+
+// initZstd creates the shared zstd encoder and decoder, both are safe for concurrent use with EncodeAll and DecodeAll.
+func initZstd() error {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedSize))
+	})
+	return zstdErr
+}
@@ -0,0 +1,69 @@
+package cryptox
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type note struct {
+	Title Stringx
+	Body  Stringx
+}
+
+func TestCompression(t *testing.T) {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	privateKey, publicKey, err := GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	large := strings.Repeat("the same note over and over again. ", 100)
+	for _, compression := range []Compression{CompressionZstd, CompressionDeflate} {
+		t.Run(string(compression), func(t *testing.T) {
+			crypto, err := New([]string{key}, nil, nil, WithCompression(compression, 0))
+			assert.NoError(t, err)
+			plain, err := New([]string{key}, nil, nil)
+			assert.NoError(t, err)
+			value := &note{Title: Stringx{Body: "short secret"}, Body: Stringx{Body: large}}
+			assert.NoError(t, crypto.Encrypt(value))
+			// short bodies stay uncompressed
+			assert.Empty(t, value.Title.Compression)
+			assert.Equal(t, string(compression), value.Body.Compression)
+			assert.Less(t, len(value.Body.Body), len(large)/4)
+			report, err := crypto.Inspect(value)
+			assert.NoError(t, err)
+			assert.Equal(t, string(compression), report.Fields[1].Compression)
+			// decryption is transparent, also without the option
+			assert.NoError(t, plain.Decrypt(value))
+			assert.Equal(t, large, value.Body.Body)
+			assert.Equal(t, "short secret", value.Title.Body)
+			assert.Empty(t, value.Body.Compression)
+		})
+	}
+	// compression is applied below the public key layer and kept while that layer is sealed
+	crypto, err := New([]string{key}, publicKey, privateKey, WithCompression(CompressionZstd, 1024))
+	assert.NoError(t, err)
+	publicOnly, err := New([]string{key}, publicKey, nil)
+	assert.NoError(t, err)
+	value := &note{Body: Stringx{Body: large}}
+	assert.NoError(t, crypto.Encrypt(value))
+	assert.NoError(t, publicOnly.Decrypt(value))
+	assert.Equal(t, string(CompressionZstd), value.Body.Compression)
+	// the symmetric layer is already removed
+	value.Title.EncryptionLevel = 0
+	value.Body.EncryptionLevel = 0
+	assert.NoError(t, crypto.Decrypt(value))
+	assert.Equal(t, large, value.Body.Body)
+	// incompressible bodies are stored uncompressed
+	crypto, err = New([]string{key}, nil, nil, WithCompression(CompressionDeflate, 0))
+	assert.NoError(t, err)
+	random := make([]byte, 512)
+	_, err = rand.Read(random)
+	assert.NoError(t, err)
+	value = &note{Body: Stringx{Body: string(random)}}
+	assert.NoError(t, crypto.Encrypt(value))
+	assert.Empty(t, value.Body.Compression)
+	assert.NoError(t, crypto.Decrypt(value))
+	assert.Equal(t, string(random), value.Body.Body)
+}
@@ -1,9 +1,12 @@
 package cryptox
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/rsa"
-	"encoding/hex"
-	"strings"
+	"errors"
+	"io"
+	"time"
 )
 
 const (
@@ -15,72 +18,165 @@ const (
 type Crypto interface {
 	Encrypt(enc interface{}) error
 	Decrypt(dec interface{}) error
+	EncryptStringx(path string, stringx *Stringx) error
+	DecryptStringx(path string, stringx *Stringx) error
 	SetZero(val interface{}) error
 	Upgradeble(val interface{}) (bool, error)
-	EncryptionLevel(val interface{}) (int32, int32)
+	Inspect(val interface{}) (*Report, error)
+	// Deprecated: use Inspect
+	EncryptionLevel(val interface{}) (internal int32, external int32)
 	SetSymmetricEncryptionKeys(SymmetricKeys []string) error
-	GetSymmetricEncryptionKeys() ([]string, string)
+	EqualSymmetricKeys(symmetricKeys []string) bool
+	ExportSymmetricKeys(reason string) ([][]byte, error)
+	Close() error
 }
 
 type Stringx struct {
 	Body               string `json:"body"`
 	EncryptionLevel    int32  `json:"encryption_level"`
 	PublicKeyEncrypted bool   `json:"public_key_encrypted"`
+	// PublicKeyAlgorithm is the algorithm of the public key layer, empty for RSA-OAEP
+	PublicKeyAlgorithm string `json:"public_key_algorithm,omitempty"`
+	// WrappedKeys holds the content key wrapped for every recipient when PublicKeyAlgorithm is AlgorithmRecipients
+	WrappedKeys []WrappedKey `json:"wrapped_keys,omitempty"`
+	// BodyFormat is FormatJWE when the layers are compact JWEs and empty for hex encoded nonce and ciphertext
+	BodyFormat string `json:"format,omitempty"`
+	// Compression is the algorithm the body was compressed with before it was encrypted, see WithCompression
+	Compression string `json:"compression,omitempty"`
+	// KeyDerivation is the derivation of the level key, empty for the legacy KeyDerivationXOR
+	KeyDerivation string `json:"key_derivation,omitempty"`
+	// SubjectID is set when the body is also encrypted under the key of a subject, see ForSubject
+	SubjectID string `json:"subject_id,omitempty"`
 }
 
 type defaultCrypto struct {
-	SymmetricKeys []string
-	SymmetricKey  []byte
-	PublicKey     *rsa.PublicKey
-	PrivateKey    *rsa.PrivateKey
+	keys             *keyring
+	keyDerivation    KeyDerivation
+	unexportedFields UnexportedFields
+	observer         Observer
+	subjectKeyStore  SubjectKeyStore
+	// subjectKeyTimeout bounds the subject key lookups of Decrypt
+	subjectKeyTimeout time.Duration
+	keyCheckStore     KeyCheckStore
+	keyExportAudit    KeyExportAudit
+	jwe               bool
+	compression       Compression
+	// compressionThreshold is the smallest body which is compressed
+	compressionThreshold int
+	random               io.Reader
+	// subject is set on a Crypto returned by ForSubject
+	subject *subject
 }
 
-func (c *defaultCrypto) SetSymmetricEncryptionKeys(SymmetricKeys []string) error {
-	for index, key := range SymmetricKeys {
-		if strings.TrimSpace(key) == "" {
-			SymmetricKeys = append(SymmetricKeys[:index], SymmetricKeys[index+1:]...)
-		}
+// Option configures optional behaviour of a Crypto created with New.
+type Option func(c *defaultCrypto)
+
+// WithRandReader sets the source of nonces and generated keys. It defaults to crypto/rand and should only be
+// replaced to get reproducible output in tests.
+func WithRandReader(random io.Reader) Option {
+	return func(c *defaultCrypto) {
+		c.random = random
 	}
-	iKey, err := CombineSymmetricSymmetricKeys(SymmetricKeys, len(SymmetricKeys))
+}
+
+// WithUnexportedFields sets how unexported struct fields are treated when walking values.
+func WithUnexportedFields(mode UnexportedFields) Option {
+	return func(c *defaultCrypto) {
+		c.unexportedFields = mode
+	}
+}
+
+func (c *defaultCrypto) SetSymmetricEncryptionKeys(SymmetricKeys []string) error {
+	keys, err := decodeSymmetricKeys(withoutEmptyKeys(SymmetricKeys))
 	if err != nil {
 		return err
 	}
-	//Since the key is in string, we need to convert decode it to bytes
-	internlKey, err := hex.DecodeString(iKey)
+	internlKey, err := deriveLevelKey(keys, len(keys), c.derivation())
+	if err == nil {
+		err = c.checkJWELevelKey(internlKey)
+	}
 	if err != nil {
+		setZeroKeys(keys)
+		setZero(internlKey)
 		return err
 	}
-	c.SymmetricKeys = SymmetricKeys
-	c.SymmetricKey = internlKey
+	if c.keyCheckStore != nil {
+		if err := verifyKeys(context.Background(), c.keyCheckStore, keys, c.derivation()); err != nil {
+			setZeroKeys(keys)
+			setZero(internlKey)
+			return err
+		}
+	}
+	c.keys.mu.Lock()
+	defer c.keys.mu.Unlock()
+	if c.keys.closed {
+		setZeroKeys(keys)
+		setZero(internlKey)
+		return ErrClosed
+	}
+	// no operation is running, so the replaced keys can be wiped
+	setZeroKeys(c.keys.symmetricKeys)
+	setZero(c.keys.symmetricKey)
+	c.keys.symmetricKeys = keys
+	c.keys.symmetricKey = internlKey
 	return nil
 }
 
-func (c *defaultCrypto) GetSymmetricEncryptionKeys() ([]string, string) {
-	return c.SymmetricKeys, string(c.SymmetricKey)
+func New(symmetricKeys []string, publicKey *rsa.PublicKey, privateKey *rsa.PrivateKey, opts ...Option) (Crypto, error) {
+	keys, err := decodeSymmetricKeys(withoutEmptyKeys(symmetricKeys))
+	if err != nil {
+		return nil, err
+	}
+	c, err := newCrypto(keys, publicKey, privateKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
-func New(symmetricKeys []string, publicKey *rsa.PublicKey, privateKey *rsa.PrivateKey) (Crypto, error) {
-	for index, key := range symmetricKeys {
-		if strings.TrimSpace(key) == "" {
-			symmetricKeys = append(symmetricKeys[:index], symmetricKeys[index+1:]...)
-		}
-	}
+// newCrypto creates a Crypto from decoded symmetric keys, which are wiped when it is closed.
+func newCrypto(keys [][]byte, publicKey *rsa.PublicKey, privateKey *rsa.PrivateKey, opts ...Option) (*defaultCrypto, error) {
 	c := &defaultCrypto{
-		SymmetricKeys: symmetricKeys,
-		PublicKey:     publicKey,
-		PrivateKey:    privateKey,
+		keys: &keyring{
+			symmetricKeys: keys,
+			publicKey:     publicKey,
+			privateKey:    copyPrivateKey(privateKey),
+		},
+		subjectKeyTimeout: defaultSubjectKeyTimeout,
+		random:            rand.Reader,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
-	if len(symmetricKeys) > 0 {
-		iKey, err := CombineSymmetricSymmetricKeys(symmetricKeys, len(symmetricKeys))
+	if c.jwe && (c.keys.x25519PublicKey != nil || len(c.keys.recipients) > 0) {
+		c.Close()
+		return nil, errors.New("jwe only supports symmetric and rsa keys")
+	}
+	if err := c.keys.setRecipientKeyIDs(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := c.keys.setSignatureKeys(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if len(keys) > 0 {
+		key, err := deriveLevelKey(keys, len(keys), c.derivation())
 		if err != nil {
+			c.Close()
 			return nil, err
 		}
-		//Since the key is in string, we need to convert decode it to bytes
-		key, err := hex.DecodeString(iKey)
-		if err != nil {
+		c.keys.symmetricKey = key
+		if err := c.checkJWELevelKey(key); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if c.keyCheckStore != nil {
+		if err := verifyKeys(context.Background(), c.keyCheckStore, keys, c.derivation()); err != nil {
+			c.Close()
 			return nil, err
 		}
-		c.SymmetricKey = key
 	}
 	return c, nil
 }
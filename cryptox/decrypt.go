@@ -6,113 +6,145 @@ import (
 	"crypto/cipher"
 	"crypto/rsa"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"reflect"
 )
 
 func (c *defaultCrypto) Decrypt(dec interface{}) error {
-	if reflect.ValueOf(dec).Type().Kind() != reflect.Ptr {
-		return errors.New("invalid value - needs to be a pointer to object")
-	}
-	v := reflect.Indirect(reflect.ValueOf(dec))
-	if v.CanSet() == false {
-		return errors.New("cannot update value in interface")
-	}
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		// first check if type is struct -> encrypt children
-		typePtrStringx := reflect.TypeOf(field.Interface()) == reflect.TypeOf(&Stringx{})
-		typeStringx := reflect.TypeOf(field.Interface()) == reflect.TypeOf(Stringx{})
-		if typePtrStringx || typeStringx {
-			bytes, err := json.Marshal(field.Interface())
-			if err != nil {
+	if encryptable, ok := dec.(Encryptable); ok {
+		return encryptable.DecryptFields(c)
+	}
+	subjectKeys, err := c.loadSubjectKeys(dec)
+	if err != nil {
+		return err
+	}
+	defer subjectKeys.wipe()
+	release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.walk(dec, true, func(path string, stringx *Stringx) error {
+		return c.observe(OperationDecrypt, path, stringx, func(stringx *Stringx) error {
+			return c.decryptStringx(stringx, subjectKeys)
+		})
+	})
+}
+
+func (c *defaultCrypto) decryptStringx(stringx *Stringx, subjectKeys *subjectKeys) error {
+	// decrypt using symmetric Keys
+	if len(c.keys.symmetricKeys) > 0 && stringx.Body != "" && stringx.EncryptionLevel > 0 {
+		// build new key of length stringx encryption level
+		internalKey, err := c.symmetricKey(stringx.EncryptionLevel, KeyDerivation(stringx.KeyDerivation))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		err = c.decrypt(stringx, internalKey)
+		setZero(internalKey)
+		if err != nil {
+			return err
+		}
+		stringx.KeyDerivation = ""
+	}
+	// decrypt using the subject key
+	if stringx.SubjectID != "" && stringx.Body != "" {
+		key, err := subjectKeys.get(stringx.SubjectID)
+		if err != nil {
+			return err
+		}
+		if err := c.decrypt(stringx, key); err != nil {
+			return err
+		}
+		stringx.SubjectID = ""
+	}
+	// the public key layer stays encrypted when the private key is missing
+	sealed := stringx.Body != "" && stringx.PublicKeyEncrypted && !c.hasPrivateKey(stringx)
+	if stringx.Body != "" && stringx.PublicKeyEncrypted && !sealed {
+		switch stringx.publicKeyAlgorithm() {
+		case AlgorithmRecipients:
+			if err := c.decryptForRecipients(stringx); err != nil {
 				return err
 			}
-			stringx, err := c.createDecryptionStringx(bytes)
-			if err != nil {
-				return err
+		case AlgorithmRSAOAEPSHA256:
+			if stringx.BodyFormat == FormatJWE {
+				if err := decryptJWERSA(stringx, c.keys.privateKey); err != nil {
+					return err
+				}
+				break
 			}
-			// update value in interface with new value
-			if typePtrStringx {
-				field.Set(reflect.ValueOf(stringx))
-			} else {
-				field.Set(reflect.ValueOf(*stringx))
+			decryptedBytes, err := c.keys.privateKey.Decrypt(nil, []byte(stringx.Body), &rsa.OAEPOptions{Hash: crypto.SHA256})
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrAuthentication, err)
 			}
-		} else if reflect.Indirect(field).Kind() == reflect.Struct {
-			//recursive encryption todo: find a faster way
-			c.Decrypt(field.Interface())
-		} else if reflect.Indirect(field).Kind() == reflect.Map { // handle map type
-			mapTypePtrStringx := reflect.Indirect(field).Type().Elem() == reflect.TypeOf(&Stringx{})
-			mapTypeStringx := reflect.Indirect(field).Type().Elem() == reflect.TypeOf(Stringx{})
-			if mapTypePtrStringx || mapTypeStringx {
-				vMap := reflect.ValueOf(field.Interface())
-				iterator := vMap.MapRange()
-				for iterator.Next() {
-					mapValue := iterator.Value()
-					bytes, err := json.Marshal(mapValue.Interface())
-					if err != nil {
-						return err
-					}
-					stringx, err := c.createDecryptionStringx(bytes)
-					if err != nil {
-						return err
-					}
-					if mapTypePtrStringx {
-						vMap.SetMapIndex(reflect.ValueOf(iterator.Key().Interface()), reflect.ValueOf(stringx))
-					} else {
-						vMap.SetMapIndex(reflect.ValueOf(iterator.Key().Interface()), reflect.ValueOf(*stringx))
-					}
-				}
+			stringx.Body = string(decryptedBytes)
+		case AlgorithmX25519ChaCha20Poly1305:
+			decryptedBytes, err := openX25519(c.keys.x25519PrivateKey, []byte(stringx.Body))
+			if err != nil {
+				return err
 			}
+			stringx.Body = string(decryptedBytes)
+		default:
+			return fmt.Errorf("%w: unknown public key algorithm %q", ErrMalformedCiphertext, stringx.PublicKeyAlgorithm)
 		}
 	}
+	if !sealed {
+		stringx.BodyFormat = ""
+	}
+	// the body is only compressed plaintext once every layer is removed
+	if !sealed && (stringx.EncryptionLevel == 0 || len(c.keys.symmetricKeys) > 0) {
+		return decompressStringx(stringx)
+	}
 	return nil
 }
 
-func (c *defaultCrypto) createDecryptionStringx(bytes []byte) (*Stringx, error) {
-	stringx := &Stringx{}
-	if err := json.Unmarshal(bytes, stringx); err != nil {
-		return nil, err
+// hasPrivateKey reports if c holds a private key for the public key layer of stringx.
+func (c *defaultCrypto) hasPrivateKey(stringx *Stringx) bool {
+	switch stringx.publicKeyAlgorithm() {
+	case AlgorithmRecipients:
+		return c.keys.privateKey != nil || c.keys.x25519PrivateKey != nil
+	case AlgorithmRSAOAEPSHA256:
+		return c.keys.privateKey != nil
+	case AlgorithmX25519ChaCha20Poly1305:
+		return c.keys.x25519PrivateKey != nil
 	}
-	// encrypt using  symmetric Keys
-	if len(c.SymmetricKeys) > 0 && stringx.Body != "" && stringx.EncryptionLevel > 0 {
-		// build new key of length stringx encryption level
-		key, err := CombineSymmetricSymmetricKeys(c.SymmetricKeys, int(stringx.EncryptionLevel))
-		if err != nil {
-			return nil, err
-		}
-		internalKey, err := hex.DecodeString(key)
-		if err != nil {
-			return nil, err
-		}
-		if err := c.decrypt(stringx, internalKey); err != nil {
-			return nil, err
-		}
+	// unknown algorithms fail when they are decrypted
+	return true
+}
+
+// checkDecryptable fails with ErrNotDecryptable if Decrypt would leave a layer of a Stringx in val encrypted,
+// because c lacks the symmetric keys or the private key of the layer.
+func (c *defaultCrypto) checkDecryptable(val interface{}) error {
+	release, err := c.acquire()
+	if err != nil {
+		return err
 	}
-	if c.PrivateKey != nil && stringx.Body != "" && stringx.PublicKeyEncrypted == true {
-		decryptedBytes, err := c.PrivateKey.Decrypt(nil, []byte(stringx.Body), &rsa.OAEPOptions{Hash: crypto.SHA256})
-		if err != nil {
-			return nil, err
+	defer release()
+	return c.walk(val, false, func(path string, stringx *Stringx) error {
+		if stringx.Body == "" {
+			return nil
 		}
-		stringx.Body = string(decryptedBytes)
-	}
-	return stringx, nil
+		if (stringx.EncryptionLevel > 0 && len(c.keys.symmetricKeys) == 0) || (stringx.PublicKeyEncrypted && !c.hasPrivateKey(stringx)) {
+			return fmt.Errorf("%s: %w", path, ErrNotDecryptable)
+		}
+		return nil
+	})
 }
 
 func (c *defaultCrypto) decrypt(dec *Stringx, key []byte) error {
 	if dec == nil {
 		return errors.New("strinx is nil")
 	}
+	if dec.BodyFormat == FormatJWE {
+		return decryptJWEDirect(dec, key)
+	}
 	enc, err := hex.DecodeString(dec.Body)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrMalformedCiphertext, err)
 	}
 	//Create a new Cipher Block from the key
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrInvalidKey, err)
 	}
 	//Create a new GCM
 	aesGCM, err := cipher.NewGCM(block)
@@ -121,12 +153,15 @@ func (c *defaultCrypto) decrypt(dec *Stringx, key []byte) error {
 	}
 	//Get the nonce size
 	nonceSize := aesGCM.NonceSize()
+	if len(enc) < nonceSize {
+		return fmt.Errorf("%w: ciphertext shorter than nonce", ErrMalformedCiphertext)
+	}
 	//Extract the nonce from the encrypted data
 	nonce, ciphertext := enc[:nonceSize], enc[nonceSize:]
 	//Decrypt the data
 	plaintext, err := aesGCM.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrAuthentication, err)
 	}
 	dec.Body = fmt.Sprintf("%s", plaintext)
 	return nil
@@ -3,115 +3,106 @@ package cryptox
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"reflect"
 )
 
 func (c *defaultCrypto) Encrypt(enc interface{}) error {
-	if reflect.ValueOf(enc).Type().Kind() != reflect.Ptr {
-		return errors.New("invalid value - needs to be a pointer to object")
+	if encryptable, ok := enc.(Encryptable); ok {
+		return encryptable.EncryptFields(c)
 	}
-	v := reflect.Indirect(reflect.ValueOf(enc))
-	if v.CanSet() == false {
-		return errors.New("cannot update value in interface")
+	release, err := c.acquire()
+	if err != nil {
+		return err
 	}
+	defer release()
 	// todo: make this async
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		// first check if type is struct -> encrypt children
-		typePtrStringx := reflect.TypeOf(field.Interface()) == reflect.TypeOf(&Stringx{})
-		typeStringx := reflect.TypeOf(field.Interface()) == reflect.TypeOf(Stringx{})
-		if typePtrStringx || typeStringx {
-			bytes, err := json.Marshal(field.Interface())
-			if err != nil {
-				return err
-			}
-			stringx, err := c.createEncryptionStringx(bytes)
-			if err != nil {
-				return err
-			}
-			// update value in interface with new value
-			if typePtrStringx {
-				field.Set(reflect.ValueOf(stringx))
-			} else {
-				field.Set(reflect.ValueOf(*stringx))
-			}
-		} else if reflect.Indirect(field).Kind() == reflect.Struct {
-			//recursive encryption todo: find a faster way
-			c.Encrypt(field.Interface()) // do not catch
-			continue
-		} else if reflect.Indirect(field).Kind() == reflect.Map { // handle map type
-			mapTypePtrStringx := reflect.Indirect(field).Type().Elem() == reflect.TypeOf(&Stringx{})
-			mapTypeStringx := reflect.Indirect(field).Type().Elem() == reflect.TypeOf(Stringx{})
-			if mapTypePtrStringx || mapTypeStringx {
-				vMap := reflect.ValueOf(field.Interface())
-				iterator := vMap.MapRange()
-				for iterator.Next() {
-					mapValue := iterator.Value()
-					bytes, err := json.Marshal(mapValue.Interface())
-					if err != nil {
-						return err
-					}
-					stringx, err := c.createEncryptionStringx(bytes)
-					if err != nil {
-						return err
-					}
-					if mapTypePtrStringx {
-						vMap.SetMapIndex(reflect.ValueOf(iterator.Key().Interface()), reflect.ValueOf(stringx))
-					} else {
-						vMap.SetMapIndex(reflect.ValueOf(iterator.Key().Interface()), reflect.ValueOf(*stringx))
-					}
-				}
-			}
-		} else if reflect.Indirect(field).Kind() == reflect.Array {
-			// todo: implemet
-		}
-	}
-	return nil
+	return c.walk(enc, true, func(path string, stringx *Stringx) error {
+		return c.observe(OperationEncrypt, path, stringx, c.encryptStringx)
+	})
 }
 
-func (c *defaultCrypto) createEncryptionStringx(bytes []byte) (*Stringx, error) {
-	// we accept types of Stringx or ptr Stringx
-	stringx := &Stringx{}
-	if err := json.Unmarshal(bytes, stringx); err != nil {
-		return nil, err
-	}
+func (c *defaultCrypto) encryptStringx(stringx *Stringx) error {
 	// encrypt using public key first
-	if c.PublicKey != nil {
+	stringx.WrappedKeys = nil
+	stringx.BodyFormat = ""
+	// compress before any layer, compressing ciphertext gains nothing
+	stringx.Compression = ""
+	if c.publicKeyAlgorithm() != "" || c.subject != nil || len(c.keys.symmetricKeys) > 0 {
+		if err := c.compressStringx(stringx); err != nil {
+			return err
+		}
+	}
+	switch c.publicKeyAlgorithm() {
+	case AlgorithmRecipients:
+		if err := c.encryptForRecipients(stringx); err != nil {
+			return err
+		}
+		stringx.PublicKeyEncrypted = true
+		stringx.PublicKeyAlgorithm = AlgorithmRecipients
+	case AlgorithmX25519ChaCha20Poly1305:
+		sealed, err := sealX25519(c.random, c.keys.x25519PublicKey, []byte(stringx.Body))
+		if err != nil {
+			return err
+		}
+		stringx.Body = string(sealed)
+		stringx.PublicKeyEncrypted = true
+		stringx.PublicKeyAlgorithm = AlgorithmX25519ChaCha20Poly1305
+	case AlgorithmRSAOAEPSHA256:
+		if c.jwe {
+			if err := encryptJWERSA(c.random, stringx, c.keys.publicKey); err != nil {
+				return err
+			}
+			stringx.PublicKeyEncrypted = true
+			stringx.PublicKeyAlgorithm = ""
+			break
+		}
 		encryptedBytes, err := rsa.EncryptOAEP(
 			sha256.New(),
-			rand.Reader,
-			c.PublicKey,
+			c.random,
+			c.keys.publicKey,
 			[]byte(stringx.Body),
 			nil)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		stringx.Body = string(encryptedBytes)
 		stringx.PublicKeyEncrypted = true
-	} else {
+		stringx.PublicKeyAlgorithm = ""
+	default:
 		stringx.PublicKeyEncrypted = false
+		stringx.PublicKeyAlgorithm = ""
+	}
+	// encrypt using the subject key
+	if c.subject != nil && stringx.Body != "" {
+		if err := c.encrypt(stringx, c.subject.key); err != nil {
+			return err
+		}
+		stringx.SubjectID = c.subject.id
+	} else {
+		stringx.SubjectID = ""
 	}
 	// encrypt using symmetric keys
-	if len(c.SymmetricKeys) > 0 && stringx.Body != "" {
-		if err := c.encrypt(stringx, c.SymmetricKey); err != nil {
-			return nil, err
+	if len(c.keys.symmetricKeys) > 0 && stringx.Body != "" {
+		if err := c.encrypt(stringx, c.keys.symmetricKey); err != nil {
+			return err
 		}
-		stringx.EncryptionLevel = int32(len(c.SymmetricKeys))
+		stringx.EncryptionLevel = int32(len(c.keys.symmetricKeys))
+		stringx.KeyDerivation = c.storedDerivation()
 	}
-	return stringx, nil
+	return nil
 }
 
 func (c *defaultCrypto) encrypt(enc *Stringx, key []byte) error {
 	if enc == nil {
 		return errors.New("stringx is nil")
 	}
+	if c.jwe {
+		return encryptJWEDirect(c.random, enc, key)
+	}
 	plaintext := []byte(enc.Body)
 	//Create a new Cipher Block from the key
 	block, err := aes.NewCipher(key)
@@ -126,7 +117,7 @@ func (c *defaultCrypto) encrypt(enc *Stringx, key []byte) error {
 	}
 	//Create a nonce. Nonce should be from GCM
 	nonce := make([]byte, aesGCM.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+	if _, err = io.ReadFull(c.random, nonce); err != nil {
 		return err
 	}
 	//WithEncryption the data using aesGCM.Seal
@@ -0,0 +1,47 @@
+package cryptox
+
+import "fmt"
+
+// Encryptable is implemented by types with methods generated by cmd/cryptox-gen. Encrypt and Decrypt
+// call them instead of walking the value with reflection. The generated methods visit the same
+// fields in the same order as the reflection walk, so both give the same result.
+type Encryptable interface {
+	EncryptFields(c Crypto) error
+	DecryptFields(c Crypto) error
+}
+
+// CheckUnexportedField is called by the generated methods for every unexported field which could hold a Stringx.
+// Like the reflection walk it fails if c was created with WithUnexportedFields(ErrorOnUnexported).
+func CheckUnexportedField(c Crypto, path string) error {
+	if c, ok := c.(*defaultCrypto); ok && c.unexportedFields == ErrorOnUnexported {
+		return fmt.Errorf("unexported field %s cannot be processed", path)
+	}
+	return nil
+}
+
+// EncryptStringx encrypts a single Stringx. path names the field for observers, eg. "Address.Street".
+func (c *defaultCrypto) EncryptStringx(path string, stringx *Stringx) error {
+	release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.observe(OperationEncrypt, path, stringx, c.encryptStringx)
+}
+
+// DecryptStringx decrypts a single Stringx. path names the field for observers, eg. "Address.Street".
+func (c *defaultCrypto) DecryptStringx(path string, stringx *Stringx) error {
+	subjectKeys, err := c.loadSubjectKeys(stringx)
+	if err != nil {
+		return err
+	}
+	defer subjectKeys.wipe()
+	release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.observe(OperationDecrypt, path, stringx, func(stringx *Stringx) error {
+		return c.decryptStringx(stringx, subjectKeys)
+	})
+}
@@ -0,0 +1,12 @@
+package cryptox
+
+import "errors"
+
+var (
+	// ErrAuthentication is returned when a ciphertext cannot be opened with the key - usually a wrong key set.
+	ErrAuthentication = errors.New("ciphertext authentication failed")
+	// ErrMalformedCiphertext is returned when a ciphertext cannot be parsed.
+	ErrMalformedCiphertext = errors.New("malformed ciphertext")
+	// ErrInvalidKey is returned when no valid key exists for the requested level.
+	ErrInvalidKey = errors.New("invalid key")
+)
@@ -1,9 +1,10 @@
 package cryptox
 
 import (
+	"crypto/rand"
 	"encoding/hex"
 	"fmt"
-	"math/rand"
+	"math/big"
 )
 
 const (
@@ -35,14 +36,20 @@ var (
 	}
 )
 
+// GenerateSymmetricKey returns length runes drawn from crypto/rand, hex encoded.
 func GenerateSymmetricKey(length int, runes int) (string, error) {
 	runeSpecification, ok := allowedRunes[runes]
 	if !ok {
 		return "", fmt.Errorf("invalid runes not allowed %d", runes)
 	}
+	max := big.NewInt(int64(len(runeSpecification)))
 	b := make([]rune, length)
 	for i := range b {
-		b[i] = runeSpecification[rand.Intn(len(runeSpecification))]
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = runeSpecification[n.Int64()]
 	}
 	return hex.EncodeToString([]byte(string(b))), nil
 }
@@ -0,0 +1,124 @@
+package cryptox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	AlgorithmAESGCM        = "AES-GCM"
+	AlgorithmRSAOAEPSHA256 = "RSA-OAEP-SHA256"
+)
+
+// FieldReport describes how a single Stringx in an inspected value is encrypted.
+type FieldReport struct {
+	Path               string `json:"path"`
+	Empty              bool   `json:"empty"`
+	EncryptionLevel    int32  `json:"encryption_level"`
+	PublicKeyEncrypted bool   `json:"public_key_encrypted"`
+	// SymmetricAlgorithm and PublicKeyAlgorithm are empty when the layer is not applied
+	SymmetricAlgorithm string `json:"symmetric_algorithm,omitempty"`
+	PublicKeyAlgorithm string `json:"public_key_algorithm,omitempty"`
+	// KeyDerivation is the derivation of the symmetric level key
+	KeyDerivation KeyDerivation `json:"key_derivation,omitempty"`
+	// KeyID identifies the symmetric key of the level. It is empty if the key is unknown to the Crypto.
+	KeyID string `json:"key_id,omitempty"`
+	// Format is FormatJWE or empty for the hex encoded format
+	Format string `json:"format,omitempty"`
+	// Recipients are the ids of the recipients the field is encrypted for, see WithRecipients
+	Recipients []string `json:"recipients,omitempty"`
+	// Compression is the algorithm the body was compressed with, see WithCompression
+	Compression string `json:"compression,omitempty"`
+	// SubjectID is set when the field is encrypted for a subject, see ForSubject
+	SubjectID   string `json:"subject_id,omitempty"`
+	Upgradeable bool   `json:"upgradeable"`
+}
+
+// Report is the result of Inspect with one entry per Stringx found.
+type Report struct {
+	Fields []FieldReport `json:"fields"`
+}
+
+// Coverage returns the share of non empty fields which are encrypted by at least one layer.
+// A report without non empty fields is fully covered.
+func (r *Report) Coverage() float64 {
+	total, encrypted := 0, 0
+	for _, field := range r.Fields {
+		if field.Empty {
+			continue
+		}
+		total++
+		if field.EncryptionLevel > 0 || field.PublicKeyEncrypted {
+			encrypted++
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(encrypted) / float64(total)
+}
+
+// Upgradeable reports if any field in the report can be encrypted at a higher level.
+func (r *Report) Upgradeable() bool {
+	for _, field := range r.Fields {
+		if field.Upgradeable {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *defaultCrypto) Inspect(val interface{}) (*Report, error) {
+	release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	report := &Report{}
+	keyIDs := map[string]string{}
+	if err := c.walk(val, false, func(path string, stringx *Stringx) error {
+		field := FieldReport{
+			Path:               path,
+			Empty:              stringx.Body == "",
+			EncryptionLevel:    stringx.EncryptionLevel,
+			PublicKeyEncrypted: stringx.PublicKeyEncrypted,
+			Format:             stringx.BodyFormat,
+			Compression:        stringx.Compression,
+			SubjectID:          stringx.SubjectID,
+			Upgradeable:        c.upgradeable(stringx),
+		}
+		if stringx.EncryptionLevel > 0 {
+			field.SymmetricAlgorithm = AlgorithmAESGCM
+			field.KeyDerivation, _ = ParseKeyDerivation(stringx.KeyDerivation)
+			levelKey := fmt.Sprintf("%d/%s", stringx.EncryptionLevel, field.KeyDerivation)
+			if _, ok := keyIDs[levelKey]; !ok {
+				keyIDs[levelKey] = c.keyID(stringx.EncryptionLevel, field.KeyDerivation)
+			}
+			field.KeyID = keyIDs[levelKey]
+		}
+		field.PublicKeyAlgorithm = stringx.publicKeyAlgorithm()
+		for _, wrappedKey := range stringx.WrappedKeys {
+			field.Recipients = append(field.Recipients, wrappedKey.RecipientID)
+		}
+		report.Fields = append(report.Fields, field)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// keyID returns a short fingerprint of the symmetric key used at level and derivation or an empty string if it is unknown.
+func (c *defaultCrypto) keyID(level int32, derivation KeyDerivation) string {
+	if int(level) > len(c.keys.symmetricKeys) {
+		return ""
+	}
+	key, err := c.symmetricKey(level, derivation)
+	if err != nil {
+		return ""
+	}
+	defer setZero(key)
+	fingerprint := sha256.Sum256(key)
+	return hex.EncodeToString(fingerprint[:8])
+}
@@ -0,0 +1,49 @@
+package cryptox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspect(t *testing.T) {
+	keyOne, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	keyTwo, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	private, public, err := GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	c, err := New([]string{keyOne}, public, private)
+	assert.NoError(t, err)
+	complexStruct := &ComplexStruct{
+		Three: &InnerStruct{One: Stringx{Body: "one"}},
+		Four:  &Stringx{Body: "four"},
+		Six:   map[string]Stringx{"six": {Body: "six"}},
+	}
+	report, err := c.Inspect(complexStruct)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), report.Coverage())
+	assert.NoError(t, c.Encrypt(complexStruct))
+	assert.NoError(t, c.SetSymmetricEncryptionKeys([]string{keyOne, keyTwo}))
+	report, err = c.Inspect(complexStruct)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), report.Coverage())
+	assert.True(t, report.Upgradeable())
+	paths := map[string]FieldReport{}
+	for _, field := range report.Fields {
+		paths[field.Path] = field
+	}
+	for _, path := range []string{"Three.One", "Four", "Six[six]"} {
+		field, ok := paths[path]
+		assert.True(t, ok, path)
+		assert.Equal(t, int32(1), field.EncryptionLevel)
+		assert.True(t, field.PublicKeyEncrypted)
+		assert.Equal(t, AlgorithmAESGCM, field.SymmetricAlgorithm)
+		assert.Equal(t, AlgorithmRSAOAEPSHA256, field.PublicKeyAlgorithm)
+		assert.NotEmpty(t, field.KeyID)
+		assert.True(t, field.Upgradeable)
+	}
+	internal, external := c.EncryptionLevel(complexStruct)
+	assert.Equal(t, int32(1), internal)
+	assert.Equal(t, int32(1), external)
+}
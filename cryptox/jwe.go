@@ -0,0 +1,240 @@
+package cryptox
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FormatJWE is stored in Stringx.BodyFormat when every layer of the body is a compact JWE (RFC 7516).
+// Layers are nested: the outer JWE has the content type "JWE" when its plaintext is another JWE.
+const FormatJWE = "jwe"
+
+const (
+	jweAlgorithmDirect    = "dir"
+	jweAlgorithmRSAOAEP   = "RSA-OAEP-256"
+	jweEncryptionA256GCM  = "A256GCM"
+	jweContentTypeNested  = "JWE"
+	jweContentKeySize     = 32
+	jweInitVectorSize     = 12
+	jweAuthenticationSize = 16
+)
+
+// WithJWE emits every layer of new values as a compact JWE, so clients can decrypt values with a JOSE library.
+// Symmetric and subject layers use dir/A256GCM with the fingerprint of the key as kid and the public key layer
+// uses RSA-OAEP-256/A256GCM. X25519 and recipients have no JWE form and cannot be combined with it.
+// A256GCM needs 32 byte level keys, so the symmetric keys must be 32 bytes unless KeyDerivationHKDF is used.
+// Values in both formats are always decrypted.
+func WithJWE() Option {
+	return func(c *defaultCrypto) {
+		c.jwe = true
+	}
+}
+
+// checkJWELevelKey fails if new values are JWEs and key cannot be used for A256GCM.
+func (c *defaultCrypto) checkJWELevelKey(key []byte) error {
+	if c.jwe && len(key) != jweContentKeySize {
+		return fmt.Errorf("jwe needs %d byte level keys but got %d bytes, use %d byte symmetric keys or %s",
+			jweContentKeySize, len(key), jweContentKeySize, KeyDerivationHKDF)
+	}
+	return nil
+}
+
+type jweHeader struct {
+	Algorithm   string `json:"alg"`
+	Encryption  string `json:"enc"`
+	KeyID       string `json:"kid,omitempty"`
+	ContentType string `json:"cty,omitempty"`
+}
+
+// jweMessage is a parsed compact JWE.
+type jweMessage struct {
+	header       jweHeader
+	protected    string
+	encryptedKey []byte
+	initVector   []byte
+	ciphertext   []byte
+	tag          []byte
+}
+
+// sealJWE encrypts plaintext with the content key and returns the compact serialization.
+func sealJWE(random io.Reader, header jweHeader, contentKey, encryptedKey, plaintext []byte) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(headerJSON)
+	initVector := make([]byte, jweInitVectorSize)
+	if _, err := io.ReadFull(random, initVector); err != nil {
+		return "", err
+	}
+	ciphertext, tag, err := sealJWEContent(protected, contentKey, initVector, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		protected,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(initVector),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// sealJWEContent encrypts plaintext with A256GCM using the encoded protected header as additional data.
+func sealJWEContent(protected string, contentKey, initVector, plaintext []byte) ([]byte, []byte, error) {
+	aesGCM, err := newJWECipher(contentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed := aesGCM.Seal(nil, initVector, plaintext, []byte(protected))
+	return sealed[:len(sealed)-jweAuthenticationSize], sealed[len(sealed)-jweAuthenticationSize:], nil
+}
+
+func parseJWE(compact string) (*jweMessage, error) {
+	parts := strings.Split(compact, ".")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("%w: compact jwe has %d parts", ErrMalformedCiphertext, len(parts))
+	}
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		var err error
+		if decoded[i], err = base64.RawURLEncoding.DecodeString(part); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedCiphertext, err)
+		}
+	}
+	message := &jweMessage{
+		protected:    parts[0],
+		encryptedKey: decoded[1],
+		initVector:   decoded[2],
+		ciphertext:   decoded[3],
+		tag:          decoded[4],
+	}
+	if err := json.Unmarshal(decoded[0], &message.header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedCiphertext, err)
+	}
+	if message.header.Encryption != jweEncryptionA256GCM {
+		return nil, fmt.Errorf("%w: unsupported jwe encryption %q", ErrMalformedCiphertext, message.header.Encryption)
+	}
+	if len(message.initVector) != jweInitVectorSize || len(message.tag) != jweAuthenticationSize {
+		return nil, fmt.Errorf("%w: invalid jwe iv or tag size", ErrMalformedCiphertext)
+	}
+	return message, nil
+}
+
+func (m *jweMessage) open(contentKey []byte) ([]byte, error) {
+	aesGCM, err := newJWECipher(contentKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	plaintext, err := aesGCM.Open(nil, m.initVector, append(append([]byte(nil), m.ciphertext...), m.tag...), []byte(m.protected))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthentication, err)
+	}
+	return plaintext, nil
+}
+
+func newJWECipher(contentKey []byte) (cipher.AEAD, error) {
+	if len(contentKey) != jweContentKeySize {
+		return nil, fmt.Errorf("invalid key size for %s: %d", jweEncryptionA256GCM, len(contentKey))
+	}
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptJWEDirect replaces the body of stringx with a dir/A256GCM JWE under key.
+func encryptJWEDirect(random io.Reader, stringx *Stringx, key []byte) error {
+	fingerprint := sha256.Sum256(key)
+	header := jweHeader{
+		Algorithm:  jweAlgorithmDirect,
+		Encryption: jweEncryptionA256GCM,
+		KeyID:      hex.EncodeToString(fingerprint[:8]),
+	}
+	return sealJWELayer(random, stringx, header, key, nil)
+}
+
+// encryptJWERSA replaces the body of stringx with a RSA-OAEP-256/A256GCM JWE for publicKey.
+func encryptJWERSA(random io.Reader, stringx *Stringx, publicKey *rsa.PublicKey) error {
+	contentKey := make([]byte, jweContentKeySize)
+	if _, err := io.ReadFull(random, contentKey); err != nil {
+		return err
+	}
+	defer setZero(contentKey)
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), random, publicKey, contentKey, nil)
+	if err != nil {
+		return err
+	}
+	keyID, err := publicKeyID(publicKey)
+	if err != nil {
+		return err
+	}
+	header := jweHeader{
+		Algorithm:  jweAlgorithmRSAOAEP,
+		Encryption: jweEncryptionA256GCM,
+		KeyID:      keyID,
+	}
+	return sealJWELayer(random, stringx, header, contentKey, encryptedKey)
+}
+
+func sealJWELayer(random io.Reader, stringx *Stringx, header jweHeader, contentKey, encryptedKey []byte) error {
+	if stringx.BodyFormat == FormatJWE {
+		header.ContentType = jweContentTypeNested
+	}
+	compact, err := sealJWE(random, header, contentKey, encryptedKey, []byte(stringx.Body))
+	if err != nil {
+		return err
+	}
+	stringx.Body = compact
+	stringx.BodyFormat = FormatJWE
+	return nil
+}
+
+// decryptJWEDirect replaces the body of stringx with the plaintext of the dir JWE it holds.
+func decryptJWEDirect(stringx *Stringx, key []byte) error {
+	message, err := parseJWE(stringx.Body)
+	if err != nil {
+		return err
+	}
+	if message.header.Algorithm != jweAlgorithmDirect || len(message.encryptedKey) != 0 {
+		return fmt.Errorf("%w: expected jwe algorithm %s but got %s", ErrMalformedCiphertext, jweAlgorithmDirect, message.header.Algorithm)
+	}
+	plaintext, err := message.open(key)
+	if err != nil {
+		return err
+	}
+	stringx.Body = string(plaintext)
+	return nil
+}
+
+// decryptJWERSA replaces the body of stringx with the plaintext of the RSA-OAEP-256 JWE it holds.
+func decryptJWERSA(stringx *Stringx, privateKey *rsa.PrivateKey) error {
+	message, err := parseJWE(stringx.Body)
+	if err != nil {
+		return err
+	}
+	if message.header.Algorithm != jweAlgorithmRSAOAEP {
+		return fmt.Errorf("%w: expected jwe algorithm %s but got %s", ErrMalformedCiphertext, jweAlgorithmRSAOAEP, message.header.Algorithm)
+	}
+	contentKey, err := privateKey.Decrypt(nil, message.encryptedKey, &rsa.OAEPOptions{Hash: crypto.SHA256})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAuthentication, err)
+	}
+	defer setZero(contentKey)
+	plaintext, err := message.open(contentKey)
+	if err != nil {
+		return err
+	}
+	stringx.Body = string(plaintext)
+	return nil
+}
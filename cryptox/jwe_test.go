@@ -0,0 +1,156 @@
+package cryptox
+
+import (
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfc7516Example is the JWE of RFC 7516 appendix A.1: RSA-OAEP (with SHA-1) and A256GCM for the key in
+// testdata/rfc7516_a1_key.json, which is the JWK of appendix A.1.1.
+const rfc7516Example = "eyJhbGciOiJSU0EtT0FFUCIsImVuYyI6IkEyNTZHQ00ifQ." +
+	"OKOawDo13gRp2ojaHV7LFpZcgV7T6DVZKTyKOMTYUmKoTCVJRgckCL9kiMT03JGeipsEdY3mx_etLbbWSrFr05kLzcSr4qKAq7YN7e9jwQRb23nfa6c9d-" +
+	"StnImGyFDbSv04uVuxIp5Zms1gNxKKK2Da14B8S4rzVRltdYwam_lDp5XnZAYpQdb76FdIKLaVmqgfwX7XWRxv2322i-vDxRfqNzo_tETKzpVLzfiwQye" +
+	"yPGLBIO56YJ7eObdv0je81860ppamavo35UgoRdbYaBcoh9QcfylQr66oc6vFWXRcZ_ZT2LawVCWTIy3brGPi6UklfCpIMfIjf7iGdXKHzg." +
+	"48V1_ALb6US04U3b." +
+	"5eym8TW_c8SuK0ltJ3rpYIzOeDQz7TALvtu6UG9oMo4vpzs9tX_EFShS8iB7j6jiSdiwkIr3ajwQzaBtQD_A." +
+	"XFBoMYUZodetZdvTiFvSkQ"
+
+// readRFC7516Key reads the RSA key of RFC 7516 appendix A.1.1 from its JWK.
+func readRFC7516Key(t *testing.T) *rsa.PrivateKey {
+	data, err := os.ReadFile("testdata/rfc7516_a1_key.json")
+	assert.NoError(t, err)
+	jwk := map[string]string{}
+	assert.NoError(t, json.Unmarshal(data, &jwk))
+	value := func(name string) *big.Int {
+		decoded, err := base64.RawURLEncoding.DecodeString(jwk[name])
+		assert.NoError(t, err)
+		return new(big.Int).SetBytes(decoded)
+	}
+	privateKey := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: value("n"), E: int(value("e").Int64())},
+		D:         value("d"),
+		Primes:    []*big.Int{value("p"), value("q")},
+	}
+	assert.NoError(t, privateKey.Validate())
+	privateKey.Precompute()
+	return privateKey
+}
+
+// TestJWERFC7516 checks every step of the example of RFC 7516 appendix A.1.
+func TestJWERFC7516(t *testing.T) {
+	contentKey := []byte{177, 161, 244, 128, 84, 143, 225, 115, 63, 180, 3, 255, 107, 154, 212, 246, 138, 7, 110, 91, 112, 46, 34, 105, 47, 130, 203, 46, 122, 234, 64, 252}
+	initVector := []byte{227, 197, 117, 252, 2, 219, 233, 68, 180, 225, 77, 219}
+	plaintext := "The true sign of intelligence is not knowledge but imagination."
+	message, err := parseJWE(rfc7516Example)
+	assert.NoError(t, err)
+	assert.Equal(t, jweHeader{Algorithm: "RSA-OAEP", Encryption: jweEncryptionA256GCM}, message.header)
+	assert.Equal(t, initVector, message.initVector)
+	// key management: the content key is wrapped with RSA-OAEP, which uses SHA-1 unlike RSA-OAEP-256
+	privateKey := readRFC7516Key(t)
+	unwrapped, err := rsa.DecryptOAEP(sha1.New(), nil, privateKey, message.encryptedKey, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, contentKey, unwrapped)
+	// content encryption: A256GCM with the encoded protected header as additional data
+	ciphertext, tag, err := sealJWEContent(message.protected, contentKey, initVector, []byte(plaintext))
+	assert.NoError(t, err)
+	assert.Equal(t, message.ciphertext, ciphertext)
+	assert.Equal(t, message.tag, tag)
+	decrypted, err := message.open(unwrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, string(decrypted))
+	message.tag[0] ^= 1
+	_, err = message.open(contentKey)
+	assert.ErrorIs(t, err, ErrAuthentication)
+	// public key layers only accept RSA-OAEP-256, so the example itself is rejected
+	value := &Stringx{Body: rfc7516Example, PublicKeyEncrypted: true, BodyFormat: FormatJWE}
+	assert.ErrorIs(t, decryptJWERSA(value, privateKey), ErrMalformedCiphertext)
+	assert.Equal(t, rfc7516Example, value.Body)
+}
+
+// TestJWEVector decrypts a nested JWE written with the crypto module of Node.js: a dir/A256GCM JWE under the
+// level key holding a RSA-OAEP-256/A256GCM JWE for the key in testdata/jwe_rsa_key.pem. The key was created with
+// "openssl genrsa -traditional 2048" and the level key and JWE were printed by "node testdata/jwe_vector.js".
+func TestJWEVector(t *testing.T) {
+	const (
+		levelKey = "5672d90cdb27cdba901bb2cc061e0c9add9966970d085a0e0c9581638d2f67e9"
+		vector   = "eyJhbGciOiJkaXIiLCJlbmMiOiJBMjU2R0NNIiwiY3R5IjoiSldFIn0..RvLhHsKYfnxCXPpQ.R7musAoy_aoIflx5Saf0AQFTE9QXfitz7FWXxBqiYF8uroL_MVhkxgFZbeHp1BrZRiD7UhP11i3eEnCXmeNDpTPQXWLpN_R0pg8pA31JkONXldnVZLElfYcLZnI8ZY-tPNCoqMKFVL8qkcsMWC7u6EJfELbAH5-v3-cnK8ZqNJmRv4P-h9GdmoJ26b0aBNsGDvZ4hek6n2aVbzRuORWd6IVPhEjFtcKCrKzvZyibjCs_c0YeAOVtSz-ZYcP_WUcaQjTaDDVCouF6X5UO4ncuHVcgsoIAYjEcXsYAS7c54Dc7tkKO8tbkGJ_VG8X7ee95YIh_Bu4JzR1WpM3UCTW4e2k8ofDCZriWD696lOTKZoDSvy_V_ay-rxjfz_6_Wm4UfP1W8uiD5yTFu_PD_anBDGc3bNX0zK_vvNkBgvVqfqQEvKYlfBj0n-dCL9yO27Hn43Bi4kXxVPmcIuFQnN3iUjb0-RUsY2OX2zHAI4Cu_ZbXArUa3VgpVcQWEt7TMy4BvBF-AX2SFi_psLMcV-ddg8ap2_NC2uimPvL7e6L-74MVPnzxjkrfd1ugVNS4IBBpKZxMpZ4Zw08Zj4GCLSitYDvJ-7Hfozo-XQkG3Lg.GzVeBUvtoRtIMkgoVYIhfg"
+	)
+	pemData, err := os.ReadFile("testdata/jwe_rsa_key.pem")
+	assert.NoError(t, err)
+	privateKey, err := ParseRsaPrivateKeyPEM(pemData)
+	assert.NoError(t, err)
+	crypto, err := New([]string{levelKey}, &privateKey.PublicKey, privateKey)
+	assert.NoError(t, err)
+	value := &InnerStruct{One: Stringx{Body: vector, EncryptionLevel: 1, PublicKeyEncrypted: true, BodyFormat: FormatJWE}}
+	assert.NoError(t, crypto.Decrypt(value))
+	assert.Equal(t, "4111 1111 1111 1111", value.One.Body)
+	assert.Empty(t, value.One.BodyFormat)
+	// every part of the compact serialization is authenticated
+	parts := strings.Split(vector, ".")
+	parts[3] = "A" + parts[3][1:]
+	value = &InnerStruct{One: Stringx{Body: strings.Join(parts, "."), EncryptionLevel: 1, PublicKeyEncrypted: true, BodyFormat: FormatJWE}}
+	assert.ErrorIs(t, crypto.Decrypt(value), ErrAuthentication)
+}
+
+func TestJWE(t *testing.T) {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	privateKey, publicKey, err := GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	_, x25519PublicKey, err := GenerateX25519KeyPair()
+	assert.NoError(t, err)
+	_, err = New([]string{key}, nil, nil, WithJWE(), WithX25519Keys(x25519PublicKey, nil))
+	assert.Error(t, err)
+	// A256GCM needs 32 byte level keys, which HKDF derives from shorter keys
+	shortKey, err := GenerateSymmetricKey(16, AlphaNum)
+	assert.NoError(t, err)
+	_, err = New([]string{shortKey}, nil, nil, WithJWE())
+	assert.Error(t, err)
+	shortKeyCrypto, err := New([]string{shortKey}, nil, nil, WithJWE(), WithKeyDerivation(KeyDerivationHKDF))
+	assert.NoError(t, err)
+	assert.NoError(t, shortKeyCrypto.Encrypt(&InnerStruct{One: Stringx{Body: "jwe"}}))
+	hexOnly, err := New([]string{key}, nil, nil, WithJWE())
+	assert.NoError(t, err)
+	assert.Error(t, hexOnly.SetSymmetricEncryptionKeys([]string{shortKey}))
+	jweCrypto, err := New([]string{key}, publicKey, privateKey, WithJWE(), WithKeyDerivation(KeyDerivationHKDF))
+	assert.NoError(t, err)
+	value := &InnerStruct{One: Stringx{Body: "jwe"}}
+	assert.NoError(t, jweCrypto.Encrypt(value))
+	assert.Equal(t, FormatJWE, value.One.BodyFormat)
+	// the outer layer is a dir JWE holding the RSA-OAEP-256 JWE
+	levelKey, err := DeriveSymmetricKey([]string{key}, 1, KeyDerivationHKDF)
+	assert.NoError(t, err)
+	outer, err := parseJWE(value.One.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, jweAlgorithmDirect, outer.header.Algorithm)
+	assert.Equal(t, jweContentTypeNested, outer.header.ContentType)
+	inner, err := outer.open(levelKey)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(strings.Split(string(inner), ".")))
+	innerHeader, err := base64.RawURLEncoding.DecodeString(strings.Split(string(inner), ".")[0])
+	assert.NoError(t, err)
+	header := map[string]string{}
+	assert.NoError(t, json.Unmarshal(innerHeader, &header))
+	assert.Equal(t, "RSA-OAEP-256", header["alg"])
+	assert.Equal(t, "A256GCM", header["enc"])
+	// values in both formats are decrypted and reported as upgradeable when the format differs
+	hexCrypto, err := New([]string{key}, publicKey, privateKey, WithKeyDerivation(KeyDerivationHKDF))
+	assert.NoError(t, err)
+	upgradeable, err := hexCrypto.Upgradeble(value)
+	assert.NoError(t, err)
+	assert.True(t, upgradeable)
+	assert.NoError(t, hexCrypto.Decrypt(value))
+	assert.Equal(t, "jwe", value.One.Body)
+	assert.Empty(t, value.One.BodyFormat)
+	assert.NoError(t, hexCrypto.Encrypt(value))
+	assert.NoError(t, jweCrypto.Decrypt(value))
+	assert.Equal(t, "jwe", value.One.Body)
+}
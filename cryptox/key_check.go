@@ -0,0 +1,173 @@
+package cryptox
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const keyCheckPlaintext = "cryptox key check"
+
+// ErrKeyCheckFailed is returned when the symmetric keys do not match the stored key checks,
+// usually because a key is wrong or the keys are in the wrong order.
+var ErrKeyCheckFailed = errors.New("key check failed")
+
+// KeyCheck is a canary ciphertext created with the key of a single level. Decrypting it
+// proves that the key of that level is the same as when the check was created.
+type KeyCheck struct {
+	Level         int32         `json:"level" bson:"level"`
+	KeyDerivation KeyDerivation `json:"key_derivation" bson:"key_derivation"`
+	Canary        string        `json:"canary" bson:"canary"`
+	CreatedAt     time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// KeyCheckStore persists key checks, eg. in a file or a collection shared by all instances of a service.
+type KeyCheckStore interface {
+	Load(ctx context.Context) ([]KeyCheck, error)
+	// Save adds the checks for levels without a check. Existing checks are never replaced.
+	Save(ctx context.Context, checks []KeyCheck) error
+}
+
+// WithKeyCheck verifies the symmetric keys against the checks in store when the Crypto is created and
+// when keys are replaced, so a wrong or reordered key set fails fast. Checks for new levels are added to the store.
+// Checks of levels above the number of keys are skipped, so instances which have not been given the key of a new
+// level yet keep starting during a rollout.
+func WithKeyCheck(store KeyCheckStore) Option {
+	return func(c *defaultCrypto) {
+		c.keyCheckStore = store
+	}
+}
+
+// VerifyKeyChecks verifies symmetricKeys against store and adds checks for levels which do not have one yet.
+// Only the levels of symmetricKeys are verified, see WithKeyCheck.
+func VerifyKeyChecks(ctx context.Context, store KeyCheckStore, symmetricKeys []string, derivation KeyDerivation) error {
+	keys, err := decodeSymmetricKeys(withoutEmptyKeys(symmetricKeys))
+	if err != nil {
+		return err
+	}
+	defer setZeroKeys(keys)
+	return verifyKeys(ctx, store, keys, derivation)
+}
+
+func verifyKeys(ctx context.Context, store KeyCheckStore, symmetricKeys [][]byte, derivation KeyDerivation) error {
+	if derivation == "" {
+		derivation = KeyDerivationXOR
+	}
+	checks, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if err := verifyKeyChecks(checks, symmetricKeys); err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, check := range checks {
+		existing[keyCheckID(check.Level, check.KeyDerivation)] = true
+	}
+	var missing []KeyCheck
+	for level := int32(1); int(level) <= len(symmetricKeys); level++ {
+		if existing[keyCheckID(level, derivation)] {
+			continue
+		}
+		check, err := createKeyCheck(symmetricKeys, level, derivation)
+		if err != nil {
+			return err
+		}
+		missing = append(missing, *check)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if err := store.Save(ctx, missing); err != nil {
+		return err
+	}
+	// another instance may have saved checks concurrently, so verify what was actually stored
+	if checks, err = store.Load(ctx); err != nil {
+		return err
+	}
+	return verifyKeyChecks(checks, symmetricKeys)
+}
+
+// verifyKeyChecks verifies the checks of the levels of symmetricKeys and skips the checks of higher levels.
+func verifyKeyChecks(checks []KeyCheck, symmetricKeys [][]byte) error {
+	for _, check := range checks {
+		if check.Level < 1 {
+			return fmt.Errorf("%w: invalid level %d", ErrKeyCheckFailed, check.Level)
+		}
+		if int(check.Level) > len(symmetricKeys) {
+			continue
+		}
+		key, err := deriveLevelKey(symmetricKeys, int(check.Level), check.KeyDerivation)
+		if err != nil {
+			return err
+		}
+		err = openKeyCheck(key, check)
+		setZero(key)
+		if err != nil {
+			return fmt.Errorf("%w: level %d with %s derivation: %v", ErrKeyCheckFailed, check.Level, check.KeyDerivation, err)
+		}
+	}
+	return nil
+}
+
+func createKeyCheck(symmetricKeys [][]byte, level int32, derivation KeyDerivation) (*KeyCheck, error) {
+	key, err := deriveLevelKey(symmetricKeys, int(level), derivation)
+	if err != nil {
+		return nil, err
+	}
+	defer setZero(key)
+	aesGCM, err := newKeyCheckCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return &KeyCheck{
+		Level:         level,
+		KeyDerivation: derivation,
+		Canary:        hex.EncodeToString(aesGCM.Seal(nonce, nonce, []byte(keyCheckPlaintext), []byte(keyCheckID(level, derivation)))),
+		CreatedAt:     time.Now().UTC(),
+	}, nil
+}
+
+func openKeyCheck(key []byte, check KeyCheck) error {
+	aesGCM, err := newKeyCheckCipher(key)
+	if err != nil {
+		return err
+	}
+	canary, err := hex.DecodeString(check.Canary)
+	if err != nil || len(canary) < aesGCM.NonceSize() {
+		return ErrMalformedCiphertext
+	}
+	nonce, ciphertext := canary[:aesGCM.NonceSize()], canary[aesGCM.NonceSize():]
+	plaintext, err := aesGCM.Open(nil, nonce, ciphertext, []byte(keyCheckID(check.Level, check.KeyDerivation)))
+	if err != nil || string(plaintext) != keyCheckPlaintext {
+		return ErrAuthentication
+	}
+	return nil
+}
+
+func newKeyCheckCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyCheckID identifies the check of a level and derivation. It is also used as additional data of the canary.
+func keyCheckID(level int32, derivation KeyDerivation) string {
+	if derivation == "" {
+		derivation = KeyDerivationXOR
+	}
+	return strconv.Itoa(int(level)) + "/" + string(derivation)
+}
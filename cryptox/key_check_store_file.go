@@ -0,0 +1,64 @@
+package cryptox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+type fileKeyCheckStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileKeyCheckStore returns a KeyCheckStore which keeps the checks as JSON in the file at path.
+func NewFileKeyCheckStore(path string) KeyCheckStore {
+	return &fileKeyCheckStore{
+		path: path,
+	}
+}
+
+func (s *fileKeyCheckStore) Load(ctx context.Context) ([]KeyCheck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *fileKeyCheckStore) load() ([]KeyCheck, error) {
+	bytes, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var checks []KeyCheck
+	if err := json.Unmarshal(bytes, &checks); err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+func (s *fileKeyCheckStore) Save(ctx context.Context, checks []KeyCheck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, err := s.load()
+	if err != nil {
+		return err
+	}
+	ids := map[string]bool{}
+	for _, check := range existing {
+		ids[keyCheckID(check.Level, check.KeyDerivation)] = true
+	}
+	for _, check := range checks {
+		if !ids[keyCheckID(check.Level, check.KeyDerivation)] {
+			existing = append(existing, check)
+		}
+	}
+	bytes, err := json.MarshalIndent(existing, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, bytes, 0600)
+}
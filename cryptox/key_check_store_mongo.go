@@ -0,0 +1,56 @@
+package cryptox
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoKeyCheckStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoKeyCheckStore returns a KeyCheckStore which keeps one document per level and derivation in collection.
+// Documents are encoded with the default registry, so a client registry from NewBSONRegistry is bypassed.
+func NewMongoKeyCheckStore(collection *mongo.Collection) KeyCheckStore {
+	return &mongoKeyCheckStore{
+		collection: collection,
+	}
+}
+
+func (s *mongoKeyCheckStore) Load(ctx context.Context) ([]KeyCheck, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var checks []KeyCheck
+	for cursor.Next(ctx) {
+		var check KeyCheck
+		if err := bson.Unmarshal(cursor.Current, &check); err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, cursor.Err()
+}
+
+func (s *mongoKeyCheckStore) Save(ctx context.Context, checks []KeyCheck) error {
+	for _, check := range checks {
+		raw, err := bson.Marshal(check)
+		if err != nil {
+			return err
+		}
+		// $setOnInsert keeps the check of whichever instance saved first
+		if _, err := s.collection.UpdateOne(ctx,
+			bson.M{"_id": keyCheckID(check.Level, check.KeyDerivation)},
+			bson.M{"$setOnInsert": bson.Raw(raw)},
+			options.Update().SetUpsert(true),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package cryptox
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyCheck(t *testing.T) {
+	store := NewFileKeyCheckStore(filepath.Join(t.TempDir(), "key_checks.json"))
+	keyOne, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	keyTwo, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	// the first start creates the checks
+	_, err = New([]string{keyOne}, nil, nil, WithKeyCheck(store))
+	assert.NoError(t, err)
+	checks, err := store.Load(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, checks, 1)
+	// adding a level adds a check
+	c, err := New([]string{keyOne, keyTwo}, nil, nil, WithKeyCheck(store))
+	assert.NoError(t, err)
+	checks, err = store.Load(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, checks, 2)
+	// wrong and reordered keys fail fast
+	_, err = New([]string{keyTwo, keyOne}, nil, nil, WithKeyCheck(store))
+	assert.ErrorIs(t, err, ErrKeyCheckFailed)
+	_, err = New([]string{keyTwo}, nil, nil, WithKeyCheck(store))
+	assert.ErrorIs(t, err, ErrKeyCheckFailed)
+	assert.ErrorIs(t, c.SetSymmetricEncryptionKeys([]string{keyTwo, keyOne}), ErrKeyCheckFailed)
+	// instances without the key of the new level only verify the levels they hold
+	_, err = New([]string{keyOne}, nil, nil, WithKeyCheck(store))
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetSymmetricEncryptionKeys([]string{keyOne}))
+	assert.NoError(t, VerifyKeyChecks(context.Background(), store, []string{keyOne}, KeyDerivationXOR))
+	checks, err = store.Load(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, checks, 2)
+	// a new derivation gets its own checks while the xor checks are still verified
+	_, err = New([]string{keyOne, keyTwo}, nil, nil, WithKeyCheck(store), WithKeyDerivation(KeyDerivationHKDF))
+	assert.NoError(t, err)
+	checks, err = store.Load(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, checks, 4)
+}
@@ -0,0 +1,153 @@
+package cryptox
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// KeyDerivation selects how the key of a level is built from the ordered list of symmetric keys.
+type KeyDerivation string
+
+const (
+	// KeyDerivationXOR XORs the keys of all levels. Equal or related keys weaken the result,
+	// so it is only kept to read existing data. It is stored as an empty Stringx.KeyDerivation.
+	KeyDerivationXOR KeyDerivation = "xor"
+	// KeyDerivationHKDF derives the key of a level with HKDF-SHA256 over the length prefixed keys of all levels.
+	KeyDerivationHKDF KeyDerivation = "hkdf-sha256"
+)
+
+const (
+	levelKeySize = 32
+	hkdfSalt     = "cryptox level key ladder"
+)
+
+// WithKeyDerivation selects the key derivation used for new values. Values are always decrypted with the
+// derivation they were encrypted with and Upgradeble reports values using another derivation.
+func WithKeyDerivation(derivation KeyDerivation) Option {
+	return func(c *defaultCrypto) {
+		c.keyDerivation = derivation
+	}
+}
+
+// ParseKeyDerivation parses the name of a key derivation, an empty name is the legacy KeyDerivationXOR.
+func ParseKeyDerivation(name string) (KeyDerivation, error) {
+	switch KeyDerivation(name) {
+	case "", KeyDerivationXOR:
+		return KeyDerivationXOR, nil
+	case KeyDerivationHKDF:
+		return KeyDerivationHKDF, nil
+	}
+	return "", fmt.Errorf("unknown key derivation %q", name)
+}
+
+// DeriveSymmetricKey returns the key of level built from the first level hex encoded symmetric keys.
+func DeriveSymmetricKey(symmetricKeys []string, level int, derivation KeyDerivation) ([]byte, error) {
+	keys, err := decodeSymmetricKeys(symmetricKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer setZeroKeys(keys)
+	return deriveLevelKey(keys, level, derivation)
+}
+
+func deriveLevelKey(symmetricKeys [][]byte, level int, derivation KeyDerivation) ([]byte, error) {
+	switch derivation {
+	case "", KeyDerivationXOR:
+		return xorLevelKey(symmetricKeys, level)
+	case KeyDerivationHKDF:
+		return hkdfLevelKey(symmetricKeys, level)
+	}
+	return nil, fmt.Errorf("unknown key derivation %q", derivation)
+}
+
+func hkdfLevelKey(symmetricKeys [][]byte, level int) ([]byte, error) {
+	if len(symmetricKeys) == 0 {
+		return nil, errors.New("invalid number of SymmetricKeys 0")
+	} else if level > len(symmetricKeys) {
+		return nil, errors.New("level cannot be larger than amount of encryption SymmetricKeys")
+	} else if level <= 0 {
+		return nil, errors.New("level cannot be less than 0")
+	}
+	// every key is length prefixed so the input cannot be split differently into keys
+	var secret []byte
+	defer func() { setZero(secret) }()
+	for _, key := range symmetricKeys[:level] {
+		if len(key) < 16 {
+			return nil, fmt.Errorf("invalid key size: %d", len(key)*2)
+		}
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(key)))
+		secret = append(append(secret, length...), key...)
+	}
+	key := make([]byte, levelKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, []byte(hkdfSalt), []byte("level "+strconv.Itoa(level))), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// decodeSymmetricKeys decodes hex encoded symmetric keys. The result should be wiped with setZeroKeys.
+func decodeSymmetricKeys(symmetricKeys []string) ([][]byte, error) {
+	keys := make([][]byte, 0, len(symmetricKeys))
+	for _, symmetricKey := range symmetricKeys {
+		key, err := hex.DecodeString(symmetricKey)
+		if err != nil {
+			setZeroKeys(keys)
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// symmetricKey returns a copy of the key of level for derivation, reusing the key of the highest level when possible.
+// The caller should wipe the key with setZero.
+func (c *defaultCrypto) symmetricKey(level int32, derivation KeyDerivation) ([]byte, error) {
+	if derivation == "" {
+		derivation = KeyDerivationXOR
+	}
+	if int(level) == len(c.keys.symmetricKeys) && derivation == c.derivation() && c.keys.symmetricKey != nil {
+		return append([]byte(nil), c.keys.symmetricKey...), nil
+	}
+	return deriveLevelKey(c.keys.symmetricKeys, int(level), derivation)
+}
+
+func (c *defaultCrypto) derivation() KeyDerivation {
+	if c.keyDerivation == "" {
+		return KeyDerivationXOR
+	}
+	return c.keyDerivation
+}
+
+// storedDerivation is the value written to Stringx.KeyDerivation. XOR is stored as empty to match existing data.
+func (c *defaultCrypto) storedDerivation() string {
+	if c.derivation() == KeyDerivationXOR {
+		return ""
+	}
+	return string(c.derivation())
+}
+
+// upgradeable reports if stringx should be encrypted again to use the highest level, the selected key derivation,
+// the selected public key algorithm and the selected format.
+func (c *defaultCrypto) upgradeable(stringx *Stringx) bool {
+	if stringx.Body == "" {
+		return false
+	}
+	if len(c.keys.symmetricKeys) > int(stringx.EncryptionLevel) {
+		return true
+	}
+	if stringx.PublicKeyEncrypted && c.publicKeyAlgorithm() != "" && stringx.publicKeyAlgorithm() != c.publicKeyAlgorithm() {
+		return true
+	}
+	if (stringx.EncryptionLevel > 0 || stringx.PublicKeyEncrypted) && (stringx.BodyFormat == FormatJWE) != c.jwe {
+		return true
+	}
+	return stringx.EncryptionLevel > 0 && stringx.KeyDerivation != c.storedDerivation()
+}
@@ -0,0 +1,62 @@
+package cryptox
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyDerivation(t *testing.T) {
+	keyOne, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	// equal keys cancel each other out with xor but not with hkdf
+	xorKey, err := DeriveSymmetricKey([]string{keyOne, keyOne}, 2, KeyDerivationXOR)
+	assert.NoError(t, err)
+	assert.Equal(t, make([]byte, 32), xorKey)
+	hkdfKey, err := DeriveSymmetricKey([]string{keyOne, keyOne}, 2, KeyDerivationHKDF)
+	assert.NoError(t, err)
+	assert.Len(t, hkdfKey, 32)
+	assert.NotEqual(t, make([]byte, 32), hkdfKey)
+	levelOne, err := DeriveSymmetricKey([]string{keyOne, keyOne}, 1, KeyDerivationHKDF)
+	assert.NoError(t, err)
+	assert.NotEqual(t, levelOne, hkdfKey)
+	// xor derivation matches the legacy combination
+	keyTwo, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	combined, err := CombineSymmetricSymmetricKeys([]string{keyOne, keyTwo}, 2)
+	assert.NoError(t, err)
+	xorKey, err = DeriveSymmetricKey([]string{keyOne, keyTwo}, 2, KeyDerivationXOR)
+	assert.NoError(t, err)
+	assert.Equal(t, combined, hex.EncodeToString(xorKey))
+	_, err = CombineSymmetricSymmetricKeys([]string{keyOne, keyTwo[:32]}, 2)
+	assert.Error(t, err)
+}
+
+func TestKeyDerivationMigration(t *testing.T) {
+	keyOne, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	keyTwo, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	legacy, err := New([]string{keyOne, keyTwo}, nil, nil)
+	assert.NoError(t, err)
+	value := &InnerStruct{One: Stringx{Body: "one"}, Two: &Stringx{Body: "two"}}
+	assert.NoError(t, legacy.Encrypt(value))
+	assert.Equal(t, "", value.One.KeyDerivation)
+	// xor encrypted data stays readable and is reported as upgradeable
+	c, err := New([]string{keyOne, keyTwo}, nil, nil, WithKeyDerivation(KeyDerivationHKDF))
+	assert.NoError(t, err)
+	upgradable, err := c.Upgradeble(value)
+	assert.NoError(t, err)
+	assert.True(t, upgradable)
+	assert.NoError(t, c.Decrypt(value))
+	assert.Equal(t, "one", value.One.Body)
+	assert.NoError(t, c.Encrypt(value))
+	assert.Equal(t, string(KeyDerivationHKDF), value.One.KeyDerivation)
+	upgradable, err = c.Upgradeble(value)
+	assert.NoError(t, err)
+	assert.False(t, upgradable)
+	// the legacy crypto can read the new ladder as the derivation is stored in the value
+	assert.NoError(t, legacy.Decrypt(value))
+	assert.Equal(t, "two", value.Two.Body)
+}
@@ -0,0 +1,369 @@
+package cryptox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keyFileVersion  = 1
+	keyFileCipher   = "AES-256-GCM"
+	keyFileSaltSize = 16
+	kekSize         = 32
+	// the minimum KDF parameters keep a modified key file or export from asking for a cheap derivation and
+	// the maximums bound the time and memory spent on key files and exports read from elsewhere
+	minScryptN       = 1 << 14
+	maxScryptN       = 1 << 20
+	maxScryptR       = 32
+	maxScryptP       = 16
+	maxArgon2Time    = 16
+	minArgon2Memory  = 8 << 10
+	maxArgon2Memory  = 2 << 20
+	maxArgon2Threads = 16
+)
+
+// KeyFileKDF is the function deriving the key encryption key of a key file from the passphrase.
+type KeyFileKDF string
+
+const (
+	KDFScrypt   KeyFileKDF = "scrypt"
+	KDFArgon2id KeyFileKDF = "argon2id"
+)
+
+// KeyFile holds the keys of a Crypto encrypted with a key derived from a passphrase, so it can be
+// stored next to the code in a secret repository. The header is authenticated together with the keys.
+type KeyFile struct {
+	Version    int              `json:"version"`
+	KDF        KeyFileKDFParams `json:"kdf"`
+	Cipher     string           `json:"cipher"`
+	Nonce      []byte           `json:"nonce"`
+	Ciphertext []byte           `json:"ciphertext"`
+}
+
+// KeyFileKDFParams are the parameters of the key derivation of a KeyFile.
+type KeyFileKDFParams struct {
+	Name KeyFileKDF `json:"name"`
+	Salt []byte     `json:"salt"`
+	// N, R and P are the scrypt parameters
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+	// Time, Memory (in KiB) and Threads are the argon2id parameters
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+}
+
+// KeyFileKeys are the keys stored in a KeyFile. Symmetric keys are raw bytes, not hex encoded.
+type KeyFileKeys struct {
+	SymmetricKeys    [][]byte
+	PrivateKey       *rsa.PrivateKey
+	X25519PrivateKey *X25519PrivateKey
+	KeyDerivation    KeyDerivation
+}
+
+// keyFilePayload is the plaintext of a KeyFile.
+type keyFilePayload struct {
+	SymmetricKeys    [][]byte      `json:"symmetric_keys"`
+	PrivateKey       []byte        `json:"private_key,omitempty"`
+	X25519PrivateKey []byte        `json:"x25519_private_key,omitempty"`
+	KeyDerivation    KeyDerivation `json:"key_derivation,omitempty"`
+}
+
+// KeyFileOption configures the key derivation of a KeyFile.
+type KeyFileOption func(params *KeyFileKDFParams)
+
+// WithScrypt derives the key encryption key with scrypt. This is the default with N=32768, r=8 and p=1.
+// N is between 2^14 and 2^20, r at most 32 and p at most 16.
+func WithScrypt(n, r, p int) KeyFileOption {
+	return func(params *KeyFileKDFParams) {
+		*params = KeyFileKDFParams{Name: KDFScrypt, N: n, R: r, P: p}
+	}
+}
+
+// WithArgon2id derives the key encryption key with argon2id using memory KiB, between 8 MiB and 2 GiB.
+func WithArgon2id(time, memory uint32, threads uint8) KeyFileOption {
+	return func(params *KeyFileKDFParams) {
+		*params = KeyFileKDFParams{Name: KDFArgon2id, Time: time, Memory: memory, Threads: threads}
+	}
+}
+
+// CreateKeyFile encrypts keys with a key derived from passphrase.
+func CreateKeyFile(passphrase []byte, keys *KeyFileKeys, opts ...KeyFileOption) (*KeyFile, error) {
+	f := &KeyFile{
+		KDF: KeyFileKDFParams{Name: KDFScrypt, N: 1 << 15, R: 8, P: 1},
+	}
+	for _, opt := range opts {
+		opt(&f.KDF)
+	}
+	if err := f.seal(passphrase, keys); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ParseKeyFile parses a KeyFile written by KeyFile.Marshal.
+func ParseKeyFile(data []byte) (*KeyFile, error) {
+	f := &KeyFile{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+	if f.Version != keyFileVersion {
+		return nil, fmt.Errorf("unsupported key file version %d", f.Version)
+	}
+	if f.Cipher != keyFileCipher {
+		return nil, fmt.Errorf("unsupported key file cipher %q", f.Cipher)
+	}
+	return f, nil
+}
+
+// ReadKeyFile reads and parses the KeyFile at path.
+func ReadKeyFile(path string) (*KeyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyFile(data)
+}
+
+func (f *KeyFile) Marshal() ([]byte, error) {
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// Write writes the KeyFile to path, readable by the owner only.
+func (f *KeyFile) Write(path string) error {
+	data, err := f.Marshal()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0600)
+}
+
+// Unlock decrypts the keys. The caller should wipe them with KeyFileKeys.Wipe when done.
+func (f *KeyFile) Unlock(passphrase []byte) (*KeyFileKeys, error) {
+	aesGCM, err := f.KDF.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != aesGCM.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid key file nonce", ErrAuthentication)
+	}
+	aad, err := f.header()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aesGCM.Open(nil, f.Nonce, f.Ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong passphrase or corrupted key file", ErrAuthentication)
+	}
+	defer setZero(plaintext)
+	payload := &keyFilePayload{}
+	if err := json.Unmarshal(plaintext, payload); err != nil {
+		return nil, err
+	}
+	defer setZero(payload.PrivateKey)
+	defer setZero(payload.X25519PrivateKey)
+	keys := &KeyFileKeys{
+		SymmetricKeys: payload.SymmetricKeys,
+		KeyDerivation: payload.KeyDerivation,
+	}
+	if len(payload.PrivateKey) > 0 {
+		if keys.PrivateKey, err = ParseRsaPrivateKeyPEM(payload.PrivateKey); err != nil {
+			keys.Wipe()
+			return nil, err
+		}
+	}
+	if len(payload.X25519PrivateKey) > 0 {
+		if len(payload.X25519PrivateKey) != X25519KeySize {
+			keys.Wipe()
+			return nil, fmt.Errorf("invalid x25519 key size: %d", len(payload.X25519PrivateKey))
+		}
+		keys.X25519PrivateKey = &X25519PrivateKey{}
+		copy(keys.X25519PrivateKey[:], payload.X25519PrivateKey)
+	}
+	return keys, nil
+}
+
+// AddLevel appends symmetricKey as the key of a new level. Level keys are 32 bytes.
+func (f *KeyFile) AddLevel(passphrase []byte, symmetricKey []byte) error {
+	if len(symmetricKey) != levelKeySize {
+		return fmt.Errorf("invalid key size: %d", len(symmetricKey)*2)
+	}
+	keys, err := f.Unlock(passphrase)
+	if err != nil {
+		return err
+	}
+	defer keys.Wipe()
+	keys.SymmetricKeys = append(keys.SymmetricKeys, append([]byte(nil), symmetricKey...))
+	return f.seal(passphrase, keys)
+}
+
+// ChangePassphrase encrypts the keys again with a key derived from newPassphrase.
+func (f *KeyFile) ChangePassphrase(oldPassphrase, newPassphrase []byte) error {
+	keys, err := f.Unlock(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	defer keys.Wipe()
+	return f.seal(newPassphrase, keys)
+}
+
+// seal encrypts keys with a new salt and nonce.
+func (f *KeyFile) seal(passphrase []byte, keys *KeyFileKeys) error {
+	payload := &keyFilePayload{
+		SymmetricKeys: keys.SymmetricKeys,
+		KeyDerivation: keys.KeyDerivation,
+	}
+	if keys.PrivateKey != nil {
+		payload.PrivateKey = EncodeRsaPrivateKeyPEM(keys.PrivateKey)
+		defer setZero(payload.PrivateKey)
+	}
+	if keys.X25519PrivateKey != nil {
+		payload.X25519PrivateKey = keys.X25519PrivateKey[:]
+	}
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	defer setZero(plaintext)
+	f.Version = keyFileVersion
+	f.Cipher = keyFileCipher
+	f.KDF.Salt = make([]byte, keyFileSaltSize)
+	if _, err := io.ReadFull(rand.Reader, f.KDF.Salt); err != nil {
+		return err
+	}
+	aesGCM, err := f.KDF.cipher(passphrase)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, f.Nonce); err != nil {
+		return err
+	}
+	aad, err := f.header()
+	if err != nil {
+		return err
+	}
+	f.Ciphertext = aesGCM.Seal(nil, f.Nonce, plaintext, aad)
+	return nil
+}
+
+// header returns the authenticated header of the KeyFile.
+func (f *KeyFile) header() ([]byte, error) {
+	return json.Marshal(struct {
+		Version int              `json:"version"`
+		KDF     KeyFileKDFParams `json:"kdf"`
+		Cipher  string           `json:"cipher"`
+	}{f.Version, f.KDF, f.Cipher})
+}
+
+// cipher returns AES-256-GCM keyed with the key derived from passphrase.
+func (params *KeyFileKDFParams) cipher(passphrase []byte) (cipher.AEAD, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase is empty")
+	}
+	var kek []byte
+	var err error
+	switch params.Name {
+	case KDFScrypt:
+		if params.N < minScryptN || params.N > maxScryptN || params.R < 1 || params.R > maxScryptR || params.P < 1 || params.P > maxScryptP {
+			return nil, errors.New("invalid scrypt parameters")
+		}
+		kek, err = scrypt.Key(passphrase, params.Salt, params.N, params.R, params.P, kekSize)
+	case KDFArgon2id:
+		if params.Time == 0 || params.Time > maxArgon2Time || params.Memory < minArgon2Memory || params.Memory > maxArgon2Memory ||
+			params.Threads == 0 || params.Threads > maxArgon2Threads {
+			return nil, errors.New("invalid argon2id parameters")
+		}
+		kek = argon2.IDKey(passphrase, params.Salt, params.Time, params.Memory, params.Threads, kekSize)
+	default:
+		return nil, fmt.Errorf("unknown key file kdf %q", params.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer setZero(kek)
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Wipe zeroes the keys.
+func (k *KeyFileKeys) Wipe() {
+	setZeroKeys(k.SymmetricKeys)
+	setZeroPrivateKey(k.PrivateKey)
+	if k.X25519PrivateKey != nil {
+		setZero(k.X25519PrivateKey[:])
+	}
+}
+
+// NewFromKeyFile creates a Crypto from the KeyFile at path. The key derivation stored in the file is used
+// unless opts select another one.
+func NewFromKeyFile(path string, passphrase []byte, opts ...Option) (Crypto, error) {
+	f, err := ReadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := f.Unlock(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer keys.Wipe()
+	if keys.KeyDerivation != "" {
+		opts = append([]Option{WithKeyDerivation(keys.KeyDerivation)}, opts...)
+	}
+	if keys.X25519PrivateKey != nil {
+		publicKey, err := keys.X25519PrivateKey.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		opts = append([]Option{WithX25519Keys(publicKey, keys.X25519PrivateKey)}, opts...)
+	}
+	symmetricKeys := make([][]byte, 0, len(keys.SymmetricKeys))
+	for _, key := range keys.SymmetricKeys {
+		symmetricKeys = append(symmetricKeys, append([]byte(nil), key...))
+	}
+	var publicKey *rsa.PublicKey
+	if keys.PrivateKey != nil {
+		publicKey = &rsa.PublicKey{N: copyInt(keys.PrivateKey.N), E: keys.PrivateKey.E}
+	}
+	c, err := newCrypto(symmetricKeys, publicKey, keys.PrivateKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// writeFileAtomic writes to a temporary file first so a crash never leaves a partial file behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,84 @@
+package cryptox
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyFile(t *testing.T) {
+	keyOne, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	keyTwo, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	rawOne, err := hex.DecodeString(keyOne)
+	assert.NoError(t, err)
+	rawTwo, err := hex.DecodeString(keyTwo)
+	assert.NoError(t, err)
+	privateKey, publicKey, err := GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	passphrase := []byte("correct horse battery staple")
+	for _, opt := range []KeyFileOption{WithScrypt(1<<14, 8, 1), WithArgon2id(1, 8*1024, 1)} {
+		f, err := CreateKeyFile(passphrase, &KeyFileKeys{
+			SymmetricKeys: [][]byte{append([]byte(nil), rawOne...)},
+			PrivateKey:    privateKey,
+			KeyDerivation: KeyDerivationHKDF,
+		}, opt)
+		assert.NoError(t, err)
+		_, err = f.Unlock([]byte("wrong"))
+		assert.ErrorIs(t, err, ErrAuthentication)
+		assert.Error(t, f.AddLevel(passphrase, rawTwo[:16]))
+		assert.NoError(t, f.AddLevel(passphrase, rawTwo))
+		assert.NoError(t, f.ChangePassphrase(passphrase, []byte("new passphrase")))
+		_, err = f.Unlock(passphrase)
+		assert.ErrorIs(t, err, ErrAuthentication)
+		path := filepath.Join(t.TempDir(), "keys.json")
+		assert.NoError(t, f.Write(path))
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		// the header is authenticated
+		tampered, err := ReadKeyFile(path)
+		assert.NoError(t, err)
+		tampered.KDF.P++
+		_, err = tampered.Unlock([]byte("new passphrase"))
+		assert.Error(t, err)
+		// a Crypto from the file uses both levels, the key derivation and the private key
+		fromFile, err := NewFromKeyFile(path, []byte("new passphrase"))
+		assert.NoError(t, err)
+		assert.True(t, fromFile.EqualSymmetricKeys([]string{keyOne, keyTwo}))
+		value := &InnerStruct{One: Stringx{Body: "one"}}
+		assert.NoError(t, fromFile.Encrypt(value))
+		assert.Equal(t, int32(2), value.One.EncryptionLevel)
+		assert.Equal(t, string(KeyDerivationHKDF), value.One.KeyDerivation)
+		c, err := New([]string{keyOne, keyTwo}, publicKey, privateKey, WithKeyDerivation(KeyDerivationHKDF))
+		assert.NoError(t, err)
+		assert.NoError(t, c.Decrypt(value))
+		assert.Equal(t, "one", value.One.Body)
+	}
+	// parameters outside the bounds are rejected before deriving a key
+	for _, opt := range []KeyFileOption{WithScrypt(1<<21, 8, 1), WithScrypt(1<<10, 8, 1), WithScrypt(1<<14, 64, 1),
+		WithScrypt(1<<14, 8, 32), WithArgon2id(32, 8*1024, 1), WithArgon2id(1, 4<<20, 1), WithArgon2id(1, 1024, 1),
+		WithArgon2id(1, 8*1024, 32)} {
+		_, err := CreateKeyFile(passphrase, &KeyFileKeys{SymmetricKeys: [][]byte{rawOne}}, opt)
+		assert.Error(t, err)
+	}
+	f, err := CreateKeyFile(passphrase, &KeyFileKeys{SymmetricKeys: [][]byte{rawOne}}, WithScrypt(1<<14, 8, 1))
+	assert.NoError(t, err)
+	nonce := f.Nonce
+	f.Nonce = nonce[:len(nonce)-1]
+	_, err = f.Unlock(passphrase)
+	assert.ErrorIs(t, err, ErrAuthentication)
+	f.Nonce = nonce
+	for _, n := range []int{1 << 30, 1 << 4} {
+		f.KDF.N = n
+		_, err = f.Unlock(passphrase)
+		assert.EqualError(t, err, "invalid scrypt parameters")
+	}
+	data := []byte(`{"version": 2, "cipher": "AES-256-GCM"}`)
+	_, err = ParseKeyFile(data)
+	assert.Error(t, err)
+}
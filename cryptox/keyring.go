@@ -0,0 +1,250 @@
+package cryptox
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrClosed is returned by a Crypto whose keys have been wiped.
+	ErrClosed = errors.New("crypto is closed")
+	// ErrKeyExportDisabled is returned by ExportSymmetricKeys unless the Crypto was created WithKeyExport.
+	ErrKeyExportDisabled = errors.New("key export is disabled")
+)
+
+// keyring holds the key material of a Crypto and is shared with the copies returned by ForSubject.
+// Keys are only held in byte slices so they can be wiped, and they are never wiped or replaced
+// while an operation is using them.
+type keyring struct {
+	mu     sync.RWMutex
+	closed bool
+	// symmetricKeys are the decoded keys of all levels
+	symmetricKeys [][]byte
+	// symmetricKey is the key of the highest level for the selected derivation
+	symmetricKey []byte
+	publicKey    *rsa.PublicKey
+	privateKey   *rsa.PrivateKey
+	// x25519PublicKey selects the X25519 sealed box for new values, see WithX25519Keys
+	x25519PublicKey  *X25519PublicKey
+	x25519PrivateKey *X25519PrivateKey
+	// recipients select multi recipient encryption for new values, see WithRecipients
+	recipients []Recipient
+	// privateKeyID and x25519PrivateKeyID identify the wrapped keys of values encrypted for recipients
+	privateKeyID       string
+	x25519PrivateKeyID string
+	// signingKey is used by Sign and verificationKeys by Verify, indexed by key id
+	signingKey          crypto.Signer
+	verificationKeyList []crypto.PublicKey
+	verificationKeys    map[string]crypto.PublicKey
+}
+
+// setRecipientKeyIDs validates the recipients and computes the key ids of the private keys.
+func (k *keyring) setRecipientKeyIDs() error {
+	for _, recipient := range k.recipients {
+		if err := recipient.validate(); err != nil {
+			return err
+		}
+	}
+	var err error
+	if k.privateKey != nil {
+		if k.privateKeyID, err = publicKeyID(&k.privateKey.PublicKey); err != nil {
+			return err
+		}
+	}
+	if k.x25519PrivateKey != nil {
+		publicKey, err := k.x25519PrivateKey.PublicKey()
+		if err != nil {
+			return err
+		}
+		if k.x25519PrivateKeyID, err = publicKeyID(publicKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeyExportAudit is called with the reason of every key export before any key is handed out.
+// Returning an error denies the export.
+type KeyExportAudit func(reason string) error
+
+// WithKeyExport allows the symmetric keys to be exported with ExportSymmetricKeys. Every export is passed to audit.
+func WithKeyExport(audit KeyExportAudit) Option {
+	return func(c *defaultCrypto) {
+		c.keyExportAudit = audit
+	}
+}
+
+// acquire must be called before key material is used. The returned release func must be called when done.
+func (c *defaultCrypto) acquire() (func(), error) {
+	c.keys.mu.RLock()
+	if c.keys.closed || (c.subject != nil && c.subject.closed) {
+		c.keys.mu.RUnlock()
+		return nil, ErrClosed
+	}
+	return c.keys.mu.RUnlock, nil
+}
+
+// Close wipes the key material and makes every further operation fail with ErrClosed. It waits for running
+// operations to finish. Closing a Crypto returned by ForSubject only wipes the key of the subject.
+func (c *defaultCrypto) Close() error {
+	c.keys.mu.Lock()
+	defer c.keys.mu.Unlock()
+	if c.subject != nil {
+		c.subject.closed = true
+		setZero(c.subject.key)
+		return nil
+	}
+	if c.keys.closed {
+		return nil
+	}
+	c.keys.closed = true
+	setZeroKeys(c.keys.symmetricKeys)
+	setZero(c.keys.symmetricKey)
+	setZeroPrivateKey(c.keys.privateKey)
+	if c.keys.x25519PrivateKey != nil {
+		setZero(c.keys.x25519PrivateKey[:])
+	}
+	switch signingKey := c.keys.signingKey.(type) {
+	case ed25519.PrivateKey:
+		setZero(signingKey)
+	case *rsa.PrivateKey:
+		setZeroPrivateKey(signingKey)
+	}
+	c.keys.symmetricKeys = nil
+	c.keys.symmetricKey = nil
+	c.keys.signingKey = nil
+	c.keys.privateKey = nil
+	c.keys.x25519PrivateKey = nil
+	return nil
+}
+
+// ExportSymmetricKeys returns copies of the symmetric keys of all levels. It fails with ErrKeyExportDisabled
+// unless the Crypto was created WithKeyExport, and every export is audited with reason.
+// The caller is responsible for wiping the returned keys.
+func (c *defaultCrypto) ExportSymmetricKeys(reason string) ([][]byte, error) {
+	if c.keyExportAudit == nil {
+		return nil, ErrKeyExportDisabled
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, errors.New("a reason is required to export keys")
+	}
+	release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := c.keyExportAudit(reason); err != nil {
+		return nil, err
+	}
+	keys := make([][]byte, 0, len(c.keys.symmetricKeys))
+	for _, key := range c.keys.symmetricKeys {
+		keys = append(keys, append([]byte(nil), key...))
+	}
+	return keys, nil
+}
+
+// EqualSymmetricKeys reports if symmetricKeys are the keys in use, comparing them in constant time.
+// It allows checking the configuration without exporting the keys.
+func (c *defaultCrypto) EqualSymmetricKeys(symmetricKeys []string) bool {
+	keys, err := decodeSymmetricKeys(withoutEmptyKeys(symmetricKeys))
+	if err != nil {
+		return false
+	}
+	defer setZeroKeys(keys)
+	release, err := c.acquire()
+	if err != nil {
+		return false
+	}
+	defer release()
+	equal := subtle.ConstantTimeEq(int32(len(keys)), int32(len(c.keys.symmetricKeys)))
+	for i := 0; i < len(keys) && i < len(c.keys.symmetricKeys); i++ {
+		equal &= subtle.ConstantTimeCompare(keys[i], c.keys.symmetricKeys[i])
+	}
+	return equal == 1
+}
+
+// withoutEmptyKeys returns symmetricKeys without blank keys.
+func withoutEmptyKeys(symmetricKeys []string) []string {
+	keys := make([]string, 0, len(symmetricKeys))
+	for _, key := range symmetricKeys {
+		if strings.TrimSpace(key) != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// copyPrivateKey returns a deep copy of key, so it can be wiped without touching the key of the caller.
+func copyPrivateKey(key *rsa.PrivateKey) *rsa.PrivateKey {
+	if key == nil {
+		return nil
+	}
+	cp := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{
+			N: copyInt(key.N),
+			E: key.E,
+		},
+		D: copyInt(key.D),
+	}
+	for _, prime := range key.Primes {
+		cp.Primes = append(cp.Primes, copyInt(prime))
+	}
+	cp.Precompute()
+	return cp
+}
+
+func copyInt(i *big.Int) *big.Int {
+	if i == nil {
+		return nil
+	}
+	return new(big.Int).Set(i)
+}
+
+// setZeroPrivateKey wipes the private values of key. Values precomputed in unexported fields
+// of the standard library cannot be reached and are left to the garbage collector.
+func setZeroPrivateKey(key *rsa.PrivateKey) {
+	if key == nil {
+		return
+	}
+	setZeroInt(key.D)
+	for _, prime := range key.Primes {
+		setZeroInt(prime)
+	}
+	setZeroInt(key.Precomputed.Dp)
+	setZeroInt(key.Precomputed.Dq)
+	setZeroInt(key.Precomputed.Qinv)
+	for _, value := range key.Precomputed.CRTValues {
+		setZeroInt(value.Exp)
+		setZeroInt(value.Coeff)
+		setZeroInt(value.R)
+	}
+}
+
+func setZeroInt(i *big.Int) {
+	if i == nil {
+		return
+	}
+	words := i.Bits()
+	for j := range words {
+		words[j] = 0
+	}
+	i.SetInt64(0)
+}
+
+func setZeroKeys(keys [][]byte) {
+	for _, key := range keys {
+		setZero(key)
+	}
+}
+
+func setZero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
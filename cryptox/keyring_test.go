@@ -0,0 +1,75 @@
+package cryptox
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyring(t *testing.T) {
+	keyOne, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	keyTwo, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	privateKey, publicKey, err := GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	d := privateKey.D.String()
+	var reasons []string
+	crypto, err := New([]string{keyOne, " "}, publicKey, privateKey, WithKeyExport(func(reason string) error {
+		if reason == "denied" {
+			return errors.New("denied")
+		}
+		reasons = append(reasons, reason)
+		return nil
+	}))
+	assert.NoError(t, err)
+	c := crypto.(*defaultCrypto)
+	// blank keys are dropped
+	assert.True(t, crypto.EqualSymmetricKeys([]string{keyOne}))
+	assert.False(t, crypto.EqualSymmetricKeys([]string{keyTwo}))
+	assert.False(t, crypto.EqualSymmetricKeys([]string{keyOne, keyTwo}))
+	// keys are only exported with a reason accepted by the audit
+	_, err = crypto.ExportSymmetricKeys("")
+	assert.Error(t, err)
+	_, err = crypto.ExportSymmetricKeys("denied")
+	assert.Error(t, err)
+	exported, err := crypto.ExportSymmetricKeys("backup")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"backup"}, reasons)
+	assert.Equal(t, keyOne, hex.EncodeToString(exported[0]))
+	_, err = newTestCrypto(t).ExportSymmetricKeys("backup")
+	assert.ErrorIs(t, err, ErrKeyExportDisabled)
+	// replaced keys are wiped
+	oldKey, oldLevelKey := c.keys.symmetricKeys[0], c.keys.symmetricKey
+	assert.NoError(t, crypto.SetSymmetricEncryptionKeys([]string{keyOne, keyTwo}))
+	assert.Equal(t, make([]byte, len(oldKey)), oldKey)
+	assert.Equal(t, make([]byte, len(oldLevelKey)), oldLevelKey)
+	value := &InnerStruct{One: Stringx{Body: "one"}}
+	assert.NoError(t, crypto.Encrypt(value))
+	// closing a subject only wipes the subject key
+	subjectCrypto, err := New([]string{keyOne}, nil, nil, WithSubjectKeyStore(NewMemorySubjectKeyStore()))
+	assert.NoError(t, err)
+	forSubject, err := subjectCrypto.(SubjectCrypto).ForSubject(context.Background(), "subject")
+	assert.NoError(t, err)
+	subjectKey := forSubject.(*defaultCrypto).subject.key
+	assert.NoError(t, forSubject.Close())
+	assert.Equal(t, make([]byte, len(subjectKey)), subjectKey)
+	assert.ErrorIs(t, forSubject.Encrypt(&InnerStruct{}), ErrClosed)
+	assert.NoError(t, subjectCrypto.Encrypt(&InnerStruct{One: Stringx{Body: "one"}}))
+	// closing wipes all keys but leaves the private key of the caller untouched
+	keys, levelKey, ownPrivateKey := c.keys.symmetricKeys, c.keys.symmetricKey, c.keys.privateKey
+	assert.NoError(t, crypto.Close())
+	assert.NoError(t, crypto.Close())
+	for _, key := range append(keys, levelKey) {
+		assert.Equal(t, make([]byte, len(key)), key)
+	}
+	assert.Zero(t, ownPrivateKey.D.Sign())
+	assert.Equal(t, d, privateKey.D.String())
+	assert.ErrorIs(t, crypto.Decrypt(value), ErrClosed)
+	assert.ErrorIs(t, crypto.SetSymmetricEncryptionKeys([]string{keyOne}), ErrClosed)
+	_, err = crypto.ExportSymmetricKeys("backup")
+	assert.ErrorIs(t, err, ErrClosed)
+}
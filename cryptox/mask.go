@@ -0,0 +1,291 @@
+package cryptox
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	formvalidators "github.com/nuntiodev/x/form_validators"
+)
+
+// MinMaskKeySize is the smallest key accepted by NewMasker.
+const MinMaskKeySize = 16
+
+// maskEmailDomain is the reserved top level domain of masked emails, so staging never mails real people.
+const maskEmailDomain = "example"
+
+// MaskKind selects how a value is replaced by a Masker.
+type MaskKind string
+
+const (
+	// MaskAuto masks emails as MaskEmail, phone numbers as MaskPhone and everything else as MaskText.
+	MaskAuto MaskKind = ""
+	// MaskText replaces letters by letters of the same case and digits by digits and keeps everything else.
+	MaskText MaskKind = "text"
+	// MaskEmail replaces an email by an email in the reserved .example domain.
+	MaskEmail MaskKind = "email"
+	// MaskPhone replaces the digits of a phone number and keeps its separators, so it stays a valid phone number.
+	MaskPhone MaskKind = "phone"
+	// MaskKeep leaves the value unchanged.
+	MaskKeep MaskKind = "keep"
+)
+
+// ParseMaskKind parses the name of a MaskKind, "auto" and "" give MaskAuto.
+func ParseMaskKind(name string) (MaskKind, error) {
+	switch kind := MaskKind(strings.ToLower(name)); kind {
+	case MaskAuto, MaskText, MaskEmail, MaskPhone, MaskKeep:
+		return kind, nil
+	case "auto":
+		return MaskAuto, nil
+	}
+	return "", fmt.Errorf("unknown mask kind %q", name)
+}
+
+// MaskOption configures a Masker.
+type MaskOption func(m *Masker)
+
+// WithMaskField masks the Stringx fields named field as kind instead of detecting their kind. field is either
+// a full path as reported by Inspect or the last name of the path, eg. "email" matches "users[3].email".
+func WithMaskField(field string, kind MaskKind) MaskOption {
+	return func(m *Masker) {
+		m.fields[field] = kind
+	}
+}
+
+// Masker replaces values by format preserving pseudonyms for non-production copies of data. The pseudonyms
+// are derived from the value with a keyed hash, so equal values get equal pseudonyms and joins between
+// documents keep working, while the real values cannot be recovered without the key.
+type Masker struct {
+	key    []byte
+	fields map[string]MaskKind
+}
+
+// NewMasker returns a Masker using key. The same key gives the same pseudonyms, so it should be kept
+// for as long as masked copies are joined with each other and must never be a production key.
+func NewMasker(key []byte, opts ...MaskOption) (*Masker, error) {
+	if len(key) < MinMaskKeySize {
+		return nil, fmt.Errorf("mask key must be at least %d bytes", MinMaskKeySize)
+	}
+	m := &Masker{
+		key:    append([]byte(nil), key...),
+		fields: map[string]MaskKind{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	for field, kind := range m.fields {
+		if _, err := ParseMaskKind(string(kind)); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+	}
+	return m, nil
+}
+
+// Pseudonymize decrypts val with source, masks the body of every Stringx and encrypts the result with target.
+// source must hold every key val is encrypted with, eg. also the private key if public key encryption is used.
+func (m *Masker) Pseudonymize(source, target Crypto, val interface{}) error {
+	if err := source.Decrypt(val); err != nil {
+		return err
+	}
+	if err := m.MaskValue(val); err != nil {
+		return err
+	}
+	return target.Encrypt(val)
+}
+
+// MaskValue masks the body of every Stringx in val, which must already be decrypted.
+func (m *Masker) MaskValue(val interface{}) error {
+	return (&defaultCrypto{}).walk(val, true, func(path string, stringx *Stringx) error {
+		masked, err := m.Mask(stringx.Body, m.fieldKind(path))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		stringx.Body = masked
+		return nil
+	})
+}
+
+func (m *Masker) fieldKind(path string) MaskKind {
+	if kind, ok := m.fields[path]; ok {
+		return kind
+	}
+	return m.fields[pathName(path)]
+}
+
+// pathName returns the last field or map key of a path without slice indexes.
+func pathName(path string) string {
+	for strings.HasSuffix(path, "]") {
+		start := strings.LastIndex(path, "[")
+		if start < 0 {
+			return path
+		}
+		name := path[start+1 : len(path)-1]
+		if !isDigits(name) {
+			return name
+		}
+		path = path[:start]
+	}
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
+// Mask returns the pseudonym of value. Empty values are kept.
+func (m *Masker) Mask(value string, kind MaskKind) (string, error) {
+	if value == "" || kind == MaskKeep {
+		return value, nil
+	}
+	if kind == MaskAuto {
+		kind = detectMaskKind(value)
+	}
+	switch kind {
+	case MaskText:
+		return m.maskText(string(kind), value, value), nil
+	case MaskEmail:
+		return m.maskEmail(value)
+	case MaskPhone:
+		return m.maskPhone(value)
+	}
+	return "", fmt.Errorf("unknown mask kind %q", kind)
+}
+
+func detectMaskKind(value string) MaskKind {
+	if isEmail(value) {
+		return MaskEmail
+	}
+	if isPhoneNumber(value) {
+		return MaskPhone
+	}
+	return MaskText
+}
+
+func isEmail(value string) bool {
+	local, domain, ok := splitEmail(value)
+	return ok && local != "" && strings.Contains(strings.Trim(domain, "."), ".") && !strings.ContainsAny(value, " \t\r\n")
+}
+
+// isPhoneNumber reports values with at least six digits and only the characters of a phone number.
+func isPhoneNumber(value string) bool {
+	digits := 0
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case !strings.ContainsRune("+-. ()/", r):
+			return false
+		}
+	}
+	return digits >= 6 && formvalidators.ValidatePhoneNumber(value)
+}
+
+func splitEmail(value string) (string, string, bool) {
+	at := strings.LastIndex(value, "@")
+	if at < 0 {
+		return "", "", false
+	}
+	return value[:at], value[at+1:], true
+}
+
+// maskEmail keeps the shape of the local part, the domain is replaced by a pseudonym in the .example domain.
+// Emails are case insensitive, so the pseudonym is derived from the lower case email.
+func (m *Masker) maskEmail(value string) (string, error) {
+	local, domain, ok := splitEmail(strings.ToLower(value))
+	if !ok || local == "" || domain == "" {
+		return "", errors.New("invalid email")
+	}
+	label := strings.Split(strings.Trim(domain, "."), ".")[0]
+	// the domain is masked on its own, so emails of the same domain keep sharing a domain
+	maskedDomain := m.maskText("email-domain", domain, strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' {
+			return 'x'
+		}
+		return r
+	}, label))
+	return m.maskText(string(MaskEmail), strings.ToLower(value), local) + "@" + maskedDomain + "." + maskEmailDomain, nil
+}
+
+// maskPhone replaces every digit and retries until the result passes ValidatePhoneNumber.
+func (m *Masker) maskPhone(value string) (string, error) {
+	for attempt := 0; attempt < 16; attempt++ {
+		stream := m.stream(string(MaskPhone), fmt.Sprintf("%d:%s", attempt, phoneDigits(value)))
+		masked := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return '0' + rune(stream.next()%10)
+			}
+			return r
+		}, value)
+		if formvalidators.ValidatePhoneNumber(masked) {
+			return masked, nil
+		}
+	}
+	return "", errors.New("invalid phone number")
+}
+
+// phoneDigits returns the digits of a phone number, so formatting does not change its pseudonym.
+func phoneDigits(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+// maskText derives the pseudonym of input for purpose and gives it the shape of value.
+func (m *Masker) maskText(purpose, input, value string) string {
+	stream := m.stream(purpose, input)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsUpper(r):
+			return 'A' + rune(stream.next()%26)
+		case unicode.IsLetter(r):
+			return 'a' + rune(stream.next()%26)
+		case unicode.IsDigit(r):
+			return '0' + rune(stream.next()%10)
+		}
+		return r
+	}, value)
+}
+
+func (m *Masker) stream(purpose, input string) *maskStream {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(input))
+	return &maskStream{seed: mac.Sum(nil)}
+}
+
+// maskStream is an endless keyed byte stream, HMAC-SHA256(seed, counter) in blocks.
+type maskStream struct {
+	seed    []byte
+	block   []byte
+	counter uint64
+}
+
+func (s *maskStream) next() byte {
+	if len(s.block) == 0 {
+		mac := hmac.New(sha256.New, s.seed)
+		var counter [8]byte
+		binary.BigEndian.PutUint64(counter[:], s.counter)
+		mac.Write(counter[:])
+		s.block = mac.Sum(nil)
+		s.counter++
+	}
+	b := s.block[0]
+	s.block = s.block[1:]
+	return b
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
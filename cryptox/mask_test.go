@@ -0,0 +1,98 @@
+package cryptox
+
+import (
+	"strings"
+	"testing"
+
+	formvalidators "github.com/nuntiodev/x/form_validators"
+	"github.com/stretchr/testify/assert"
+)
+
+type customer struct {
+	Name    Stringx
+	Email   Stringx
+	Phone   Stringx
+	Orders  []order
+	Comment Stringx
+}
+
+type order struct {
+	CustomerEmail Stringx
+	Reference     Stringx
+}
+
+func TestMasker(t *testing.T) {
+	_, err := NewMasker([]byte("short"))
+	assert.Error(t, err)
+	masker, err := NewMasker([]byte("0123456789abcdef0123456789abcdef"), WithMaskField("Reference", MaskKeep))
+	assert.NoError(t, err)
+	other, err := NewMasker([]byte("fedcba9876543210fedcba9876543210"))
+	assert.NoError(t, err)
+	// emails stay emails in the reserved domain and are case insensitive
+	email, err := masker.Mask("Jane.Doe+news@Example.org", MaskAuto)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(email, ".example"))
+	assert.True(t, isEmail(email))
+	assert.NotContains(t, email, "jane")
+	lower, err := masker.Mask("jane.doe+news@example.org", MaskEmail)
+	assert.NoError(t, err)
+	assert.Equal(t, email, lower)
+	sameDomain, err := masker.Mask("john@example.org", MaskEmail)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.SplitN(email, "@", 2)[1], strings.SplitN(sameDomain, "@", 2)[1])
+	otherEmail, err := other.Mask("jane.doe+news@example.org", MaskEmail)
+	assert.NoError(t, err)
+	assert.NotEqual(t, email, otherEmail)
+	_, err = masker.Mask("not an email", MaskEmail)
+	assert.Error(t, err)
+	// phone numbers keep their format and stay valid
+	for _, phone := range []string{"+45 12 34 56 78", "0045-1234-5678", "(555) 123-4567", "+1 555 0100 ext 12"} {
+		masked, err := masker.Mask(phone, MaskPhone)
+		assert.NoError(t, err)
+		assert.NotEqual(t, phone, masked)
+		assert.Len(t, masked, len(phone))
+		assert.True(t, formvalidators.ValidatePhoneNumber(masked), masked)
+	}
+	// text keeps its shape
+	text, err := masker.Mask("Jane Doe, 42", MaskAuto)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[A-Z][a-z]{3} [A-Z][a-z]{2}, [0-9]{2}$`, text)
+	empty, err := masker.Mask("", MaskText)
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+	// documents are decrypted, masked and encrypted under other keys
+	productionKey, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	stagingKey, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	production, err := New([]string{productionKey}, nil, nil)
+	assert.NoError(t, err)
+	staging, err := New([]string{stagingKey}, nil, nil)
+	assert.NoError(t, err)
+	value := &customer{
+		Name:  Stringx{Body: "Jane Doe"},
+		Email: Stringx{Body: "jane@example.org"},
+		Phone: Stringx{Body: "+45 12 34 56 78"},
+		Orders: []order{
+			{CustomerEmail: Stringx{Body: "JANE@example.org"}, Reference: Stringx{Body: "ORDER-1"}},
+		},
+	}
+	assert.NoError(t, production.Encrypt(value))
+	assert.NoError(t, masker.Pseudonymize(production, staging, value))
+	assert.Error(t, production.Decrypt(value))
+	assert.NoError(t, staging.Decrypt(value))
+	assert.NotEqual(t, "Jane Doe", value.Name.Body)
+	assert.Len(t, value.Name.Body, len("Jane Doe"))
+	assert.Equal(t, value.Email.Body, value.Orders[0].CustomerEmail.Body)
+	assert.Equal(t, "ORDER-1", value.Orders[0].Reference.Body)
+	assert.True(t, formvalidators.ValidatePhoneNumber(value.Phone.Body))
+	assert.Empty(t, value.Comment.Body)
+}
+
+func TestPathName(t *testing.T) {
+	assert.Equal(t, "Email", pathName("Email"))
+	assert.Equal(t, "CustomerEmail", pathName("Orders[0].CustomerEmail"))
+	assert.Equal(t, "email", pathName("user[email]"))
+	assert.Equal(t, "email", pathName("users[3][email]"))
+	assert.Equal(t, "tags", pathName("user[tags][2]"))
+}
@@ -0,0 +1,115 @@
+package cryptox
+
+import (
+	"errors"
+	"time"
+)
+
+type Operation string
+
+const (
+	OperationEncrypt Operation = "encrypt"
+	OperationDecrypt Operation = "decrypt"
+)
+
+// ErrorClass groups errors reported to an Observer into a small, stable set usable as a metric label.
+type ErrorClass string
+
+const (
+	ErrorClassNone           ErrorClass = ""
+	ErrorClassAuthentication ErrorClass = "authentication"
+	ErrorClassMalformed      ErrorClass = "malformed"
+	ErrorClassKey            ErrorClass = "key"
+	ErrorClassSubjectErased  ErrorClass = "subject_erased"
+	ErrorClassOther          ErrorClass = "other"
+)
+
+// Event describes a single Stringx being encrypted or decrypted.
+type Event struct {
+	Operation Operation
+	// Path is the location of the Stringx in the value, eg. Address.Street or Emails[0]
+	Path string
+	// Level is the symmetric encryption level written by encrypt or read by decrypt
+	Level              int32
+	PublicKeyEncrypted bool
+	Duration           time.Duration
+	Err                error
+	ErrorClass         ErrorClass
+}
+
+// Observer is notified after every Stringx operation. Callbacks are invoked synchronously
+// and must be safe for concurrent use.
+type Observer interface {
+	OnEncrypt(event Event)
+	OnDecrypt(event Event)
+}
+
+// ObserverFuncs is an Observer built from optional callbacks.
+type ObserverFuncs struct {
+	Encrypt func(event Event)
+	Decrypt func(event Event)
+}
+
+func (o ObserverFuncs) OnEncrypt(event Event) {
+	if o.Encrypt != nil {
+		o.Encrypt(event)
+	}
+}
+
+func (o ObserverFuncs) OnDecrypt(event Event) {
+	if o.Decrypt != nil {
+		o.Decrypt(event)
+	}
+}
+
+// WithObserver reports every encrypt and decrypt operation to observer.
+func WithObserver(observer Observer) Option {
+	return func(c *defaultCrypto) {
+		c.observer = observer
+	}
+}
+
+// ClassifyError returns the ErrorClass of an error returned by cryptox.
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case errors.Is(err, ErrAuthentication):
+		return ErrorClassAuthentication
+	case errors.Is(err, ErrMalformedCiphertext):
+		return ErrorClassMalformed
+	case errors.Is(err, ErrSubjectErased):
+		return ErrorClassSubjectErased
+	case errors.Is(err, ErrInvalidKey):
+		return ErrorClassKey
+	}
+	return ErrorClassOther
+}
+
+// observe runs fn for stringx and reports the outcome to the observer if one is set.
+func (c *defaultCrypto) observe(operation Operation, path string, stringx *Stringx, fn func(stringx *Stringx) error) error {
+	if c.observer == nil {
+		return fn(stringx)
+	}
+	level, publicKeyEncrypted := stringx.EncryptionLevel, stringx.PublicKeyEncrypted
+	start := time.Now()
+	err := fn(stringx)
+	if operation == OperationEncrypt && err == nil {
+		level, publicKeyEncrypted = stringx.EncryptionLevel, stringx.PublicKeyEncrypted
+	}
+	event := Event{
+		Operation:          operation,
+		Path:               path,
+		Level:              level,
+		PublicKeyEncrypted: publicKeyEncrypted,
+		Duration:           time.Since(start),
+		Err:                err,
+		ErrorClass:         ClassifyError(err),
+	}
+	if operation == OperationEncrypt {
+		c.observer.OnEncrypt(event)
+	} else {
+		c.observer.OnDecrypt(event)
+	}
+	return err
+}
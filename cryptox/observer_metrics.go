@@ -0,0 +1,58 @@
+package cryptox
+
+import "strconv"
+
+// Counter is a counter vector as found in Prometheus style metric libraries.
+type Counter interface {
+	Inc(labelValues ...string)
+}
+
+// Histogram is a histogram vector as found in Prometheus style metric libraries.
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// MetricsRegistry creates the metrics used by NewMetricsObserver. Wrapping a Prometheus
+// registry only requires calling WithLabelValues on the created vectors.
+type MetricsRegistry interface {
+	NewCounter(name, help string, labelNames ...string) Counter
+	NewHistogram(name, help string, buckets []float64, labelNames ...string) Histogram
+}
+
+var DefaultDurationBuckets = []float64{.00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1}
+
+type metricsObserver struct {
+	operations Counter
+	errors     Counter
+	duration   Histogram
+}
+
+// NewMetricsObserver registers and records:
+//
+//	cryptox_operations_total{operation, level}
+//	cryptox_operation_errors_total{operation, error_class}
+//	cryptox_operation_duration_seconds{operation}
+func NewMetricsObserver(registry MetricsRegistry) Observer {
+	return &metricsObserver{
+		operations: registry.NewCounter("cryptox_operations_total", "Number of Stringx values encrypted or decrypted.", "operation", "level"),
+		errors:     registry.NewCounter("cryptox_operation_errors_total", "Number of failed Stringx operations.", "operation", "error_class"),
+		duration:   registry.NewHistogram("cryptox_operation_duration_seconds", "Time spent on a Stringx operation.", DefaultDurationBuckets, "operation"),
+	}
+}
+
+func (o *metricsObserver) OnEncrypt(event Event) {
+	o.record(event)
+}
+
+func (o *metricsObserver) OnDecrypt(event Event) {
+	o.record(event)
+}
+
+func (o *metricsObserver) record(event Event) {
+	operation := string(event.Operation)
+	o.operations.Inc(operation, strconv.Itoa(int(event.Level)))
+	o.duration.Observe(event.Duration.Seconds(), operation)
+	if event.Err != nil {
+		o.errors.Inc(operation, string(event.ErrorClass))
+	}
+}
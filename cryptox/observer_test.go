@@ -0,0 +1,84 @@
+package cryptox
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type testMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (m *testMetrics) add(name string, labelValues []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := name
+	for _, value := range labelValues {
+		key += "/" + value
+	}
+	m.counts[key]++
+}
+
+type testCounter struct {
+	name    string
+	metrics *testMetrics
+}
+
+func (c *testCounter) Inc(labelValues ...string) {
+	c.metrics.add(c.name, labelValues)
+}
+
+func (c *testCounter) Observe(value float64, labelValues ...string) {
+	c.metrics.add(c.name, labelValues)
+}
+
+func (m *testMetrics) NewCounter(name, help string, labelNames ...string) Counter {
+	return &testCounter{name: name, metrics: m}
+}
+
+func (m *testMetrics) NewHistogram(name, help string, buckets []float64, labelNames ...string) Histogram {
+	return &testCounter{name: name, metrics: m}
+}
+
+func TestObserver(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	metrics := &testMetrics{counts: map[string]int{}}
+	var events []Event
+	keyOne, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	zapObserver := NewZapObserver(zap.New(core))
+	metricsObserver := NewMetricsObserver(metrics)
+	c, err := New([]string{keyOne}, nil, nil, WithObserver(ObserverFuncs{
+		Encrypt: func(event Event) {
+			zapObserver.OnEncrypt(event)
+			metricsObserver.OnEncrypt(event)
+		},
+		Decrypt: func(event Event) {
+			events = append(events, event)
+			zapObserver.OnDecrypt(event)
+			metricsObserver.OnDecrypt(event)
+		},
+	}))
+	assert.NoError(t, err)
+	inner := &InnerStruct{One: Stringx{Body: "one"}, Two: &Stringx{Body: "two"}}
+	assert.NoError(t, c.Encrypt(inner))
+	assert.Equal(t, 2, metrics.counts["cryptox_operations_total/encrypt/1"])
+	assert.Equal(t, 2, logs.FilterMessage("cryptox operation").Len())
+	// decrypting with the wrong key is reported as an authentication error
+	keyTwo, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetSymmetricEncryptionKeys([]string{keyTwo}))
+	assert.ErrorIs(t, c.Decrypt(inner), ErrAuthentication)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "One", events[0].Path)
+	assert.Equal(t, int32(1), events[0].Level)
+	assert.Equal(t, ErrorClassAuthentication, events[0].ErrorClass)
+	assert.Equal(t, 1, metrics.counts["cryptox_operation_errors_total/decrypt/authentication"])
+	assert.Equal(t, 1, logs.FilterMessage("cryptox operation failed").Len())
+}
@@ -0,0 +1,38 @@
+package cryptox
+
+import "go.uber.org/zap"
+
+type zapObserver struct {
+	logger *zap.Logger
+}
+
+// NewZapObserver logs successful operations at debug level and failures at error level.
+func NewZapObserver(logger *zap.Logger) Observer {
+	return &zapObserver{
+		logger: logger,
+	}
+}
+
+func (o *zapObserver) OnEncrypt(event Event) {
+	o.log(event)
+}
+
+func (o *zapObserver) OnDecrypt(event Event) {
+	o.log(event)
+}
+
+func (o *zapObserver) log(event Event) {
+	fields := []zap.Field{
+		zap.String("operation", string(event.Operation)),
+		zap.String("path", event.Path),
+		zap.Int32("level", event.Level),
+		zap.Bool("public_key_encrypted", event.PublicKeyEncrypted),
+		zap.Duration("duration", event.Duration),
+	}
+	if event.Err != nil {
+		fields = append(fields, zap.String("error_class", string(event.ErrorClass)), zap.Error(event.Err))
+		o.logger.Error("cryptox operation failed", fields...)
+		return
+	}
+	o.logger.Debug("cryptox operation", fields...)
+}
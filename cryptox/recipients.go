@@ -0,0 +1,270 @@
+package cryptox
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// AlgorithmRecipients encrypts the body with AES-GCM under a random content key which is wrapped
+// for every recipient. It is stored in Stringx.PublicKeyAlgorithm.
+const AlgorithmRecipients = "AES-GCM-Recipients"
+
+const contentKeySize = 32
+
+// ErrNotRecipient is returned when a value is encrypted for recipients and none of them holds a private key of the Crypto.
+var ErrNotRecipient = errors.New("not a recipient")
+
+// Recipient is a public key a value is encrypted for.
+type Recipient struct {
+	// ID names the recipient, eg. a user id or "compliance". Recipients are revoked by their ID.
+	ID string
+	// PublicKey is either an *rsa.PublicKey or an *X25519PublicKey
+	PublicKey crypto.PublicKey
+}
+
+// validate reports recipients without an ID or with a public key content keys cannot be wrapped for.
+func (r Recipient) validate() error {
+	if r.ID == "" {
+		return errors.New("recipient id is empty")
+	}
+	switch publicKey := r.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if publicKey != nil {
+			return nil
+		}
+	case *X25519PublicKey:
+		if publicKey != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("recipient %s: unsupported public key type %T", r.ID, r.PublicKey)
+}
+
+// RecipientCrypto changes the recipients of values encrypted with WithRecipients. Every Crypto created by New
+// implements it, eg. c.(cryptox.RecipientCrypto).AddRecipients(value, recipient).
+type RecipientCrypto interface {
+	// AddRecipients wraps the content key of every Stringx in val, which is encrypted for recipients, for
+	// additional recipients. The body is not encrypted again, but the Crypto must be one of the current recipients.
+	// A recipient with an existing ID is replaced. val is left unchanged if any content key cannot be unwrapped.
+	AddRecipients(val interface{}, recipients ...Recipient) error
+	// RevokeRecipients removes the wrapped content keys of recipientIDs from every Stringx in val. Revoking does not
+	// change the content key, so a recipient who kept a copy of the value or its content key can still read it.
+	// val is left unchanged if any Stringx would lose its last recipient.
+	RevokeRecipients(val interface{}, recipientIDs ...string) error
+}
+
+// WrappedKey is the content key of a value wrapped with the public key of a recipient.
+type WrappedKey struct {
+	RecipientID string `json:"recipient_id"`
+	// KeyID is the fingerprint of the public key, used to find the wrapped key of a private key
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	Key       string `json:"key"`
+}
+
+// WithRecipients encrypts the public key layer of new values for every recipient instead of a single public key.
+// Each recipient decrypts independently with a Crypto holding its private key.
+func WithRecipients(recipients ...Recipient) Option {
+	return func(c *defaultCrypto) {
+		c.keys.recipients = append(c.keys.recipients, recipients...)
+	}
+}
+
+// AddRecipients implements RecipientCrypto.
+func (c *defaultCrypto) AddRecipients(val interface{}, recipients ...Recipient) error {
+	for _, recipient := range recipients {
+		if err := recipient.validate(); err != nil {
+			return err
+		}
+	}
+	release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	// wrap every content key before the first Stringx is changed
+	wrappedKeysByPath := map[string][]WrappedKey{}
+	if err := c.walk(val, false, func(path string, stringx *Stringx) error {
+		if stringx.PublicKeyAlgorithm != AlgorithmRecipients || len(stringx.WrappedKeys) == 0 {
+			return nil
+		}
+		contentKey, err := c.unwrapContentKey(stringx.WrappedKeys)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defer setZero(contentKey)
+		wrappedKeys := stringx.WrappedKeys
+		for _, recipient := range recipients {
+			wrappedKey, err := wrapContentKey(c.random, recipient, contentKey)
+			if err != nil {
+				return err
+			}
+			wrappedKeys = append(withoutRecipient(wrappedKeys, recipient.ID), *wrappedKey)
+		}
+		wrappedKeysByPath[path] = wrappedKeys
+		return nil
+	}); err != nil {
+		return err
+	}
+	return c.walk(val, true, func(path string, stringx *Stringx) error {
+		if wrappedKeys, ok := wrappedKeysByPath[path]; ok {
+			stringx.WrappedKeys = wrappedKeys
+		}
+		return nil
+	})
+}
+
+// RevokeRecipients implements RecipientCrypto.
+func (c *defaultCrypto) RevokeRecipients(val interface{}, recipientIDs ...string) error {
+	release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	revoke := func(wrappedKeys []WrappedKey) []WrappedKey {
+		for _, recipientID := range recipientIDs {
+			wrappedKeys = withoutRecipient(wrappedKeys, recipientID)
+		}
+		return wrappedKeys
+	}
+	// check every Stringx before the first one is changed
+	if err := c.walk(val, false, func(path string, stringx *Stringx) error {
+		if stringx.PublicKeyAlgorithm != AlgorithmRecipients || len(stringx.WrappedKeys) == 0 {
+			return nil
+		}
+		if len(revoke(stringx.WrappedKeys)) == 0 {
+			return fmt.Errorf("%s: cannot revoke the last recipient", path)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return c.walk(val, true, func(path string, stringx *Stringx) error {
+		if stringx.PublicKeyAlgorithm == AlgorithmRecipients && len(stringx.WrappedKeys) > 0 {
+			stringx.WrappedKeys = revoke(stringx.WrappedKeys)
+		}
+		return nil
+	})
+}
+
+// encryptForRecipients encrypts the body of stringx with a new content key and wraps the key for every recipient.
+func (c *defaultCrypto) encryptForRecipients(stringx *Stringx) error {
+	contentKey := make([]byte, contentKeySize)
+	if _, err := io.ReadFull(c.random, contentKey); err != nil {
+		return err
+	}
+	defer setZero(contentKey)
+	wrappedKeys := make([]WrappedKey, 0, len(c.keys.recipients))
+	for _, recipient := range c.keys.recipients {
+		wrappedKey, err := wrapContentKey(c.random, recipient, contentKey)
+		if err != nil {
+			return err
+		}
+		wrappedKeys = append(wrappedKeys, *wrappedKey)
+	}
+	if err := c.encrypt(stringx, contentKey); err != nil {
+		return err
+	}
+	stringx.WrappedKeys = wrappedKeys
+	return nil
+}
+
+// decryptForRecipients decrypts the body of stringx with the content key wrapped for one of the private keys.
+func (c *defaultCrypto) decryptForRecipients(stringx *Stringx) error {
+	contentKey, err := c.unwrapContentKey(stringx.WrappedKeys)
+	if err != nil {
+		return err
+	}
+	defer setZero(contentKey)
+	if err := c.decrypt(stringx, contentKey); err != nil {
+		return err
+	}
+	stringx.WrappedKeys = nil
+	return nil
+}
+
+func wrapContentKey(random io.Reader, recipient Recipient, contentKey []byte) (*WrappedKey, error) {
+	if err := recipient.validate(); err != nil {
+		return nil, err
+	}
+	keyID, err := publicKeyID(recipient.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey := &WrappedKey{
+		RecipientID: recipient.ID,
+		KeyID:       keyID,
+	}
+	var wrapped []byte
+	switch publicKey := recipient.PublicKey.(type) {
+	case *rsa.PublicKey:
+		wrappedKey.Algorithm = AlgorithmRSAOAEPSHA256
+		wrapped, err = rsa.EncryptOAEP(sha256.New(), random, publicKey, contentKey, nil)
+	case *X25519PublicKey:
+		wrappedKey.Algorithm = AlgorithmX25519ChaCha20Poly1305
+		wrapped, err = sealX25519(random, publicKey, contentKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey.Key = hex.EncodeToString(wrapped)
+	return wrappedKey, nil
+}
+
+// unwrapContentKey unwraps the content key with the first private key of the Crypto it is wrapped for.
+func (c *defaultCrypto) unwrapContentKey(wrappedKeys []WrappedKey) ([]byte, error) {
+	for _, wrappedKey := range wrappedKeys {
+		wrapped, err := hex.DecodeString(wrappedKey.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedCiphertext, err)
+		}
+		switch {
+		case wrappedKey.Algorithm == AlgorithmRSAOAEPSHA256 && c.keys.privateKey != nil && wrappedKey.KeyID == c.keys.privateKeyID:
+			contentKey, err := c.keys.privateKey.Decrypt(nil, wrapped, &rsa.OAEPOptions{Hash: crypto.SHA256})
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrAuthentication, err)
+			}
+			return contentKey, nil
+		case wrappedKey.Algorithm == AlgorithmX25519ChaCha20Poly1305 && c.keys.x25519PrivateKey != nil && wrappedKey.KeyID == c.keys.x25519PrivateKeyID:
+			return openX25519(c.keys.x25519PrivateKey, wrapped)
+		}
+	}
+	return nil, ErrNotRecipient
+}
+
+// publicKeyID returns a short fingerprint of an *rsa.PublicKey, *X25519PublicKey or ed25519.PublicKey.
+func publicKeyID(publicKey crypto.PublicKey) (string, error) {
+	var encoded []byte
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		var err error
+		if encoded, err = x509.MarshalPKIXPublicKey(publicKey); err != nil {
+			return "", err
+		}
+	case *X25519PublicKey:
+		encoded = publicKey[:]
+	case ed25519.PublicKey:
+		encoded = publicKey
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	fingerprint := sha256.Sum256(encoded)
+	return hex.EncodeToString(fingerprint[:8]), nil
+}
+
+func withoutRecipient(wrappedKeys []WrappedKey, recipientID string) []WrappedKey {
+	kept := make([]WrappedKey, 0, len(wrappedKeys))
+	for _, wrappedKey := range wrappedKeys {
+		if wrappedKey.RecipientID != recipientID {
+			kept = append(kept, wrappedKey)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,94 @@
+package cryptox
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecipients(t *testing.T) {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	userPrivateKey, userPublicKey, err := GenerateX25519KeyPair()
+	assert.NoError(t, err)
+	compliancePrivateKey, compliancePublicKey, err := GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	auditorPrivateKey, auditorPublicKey, err := GenerateX25519KeyPair()
+	assert.NoError(t, err)
+	signingPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	// only rsa and x25519 keys can be recipients
+	for _, publicKey := range []interface{}{"not a key", signingPublicKey, (*rsa.PublicKey)(nil), (*X25519PublicKey)(nil), nil} {
+		_, err = New([]string{key}, nil, nil, WithRecipients(Recipient{ID: "user", PublicKey: publicKey}))
+		assert.Error(t, err)
+	}
+	_, err = New([]string{key}, nil, nil, WithRecipients(Recipient{PublicKey: userPublicKey}))
+	assert.Error(t, err)
+	crypto, err := New([]string{key}, nil, nil, WithRecipients(
+		Recipient{ID: "user", PublicKey: userPublicKey},
+		Recipient{ID: "compliance", PublicKey: compliancePublicKey},
+	))
+	assert.NoError(t, err)
+	userCrypto, err := New([]string{key}, nil, nil, WithX25519Keys(nil, userPrivateKey))
+	assert.NoError(t, err)
+	complianceCrypto, err := New([]string{key}, nil, compliancePrivateKey)
+	assert.NoError(t, err)
+	auditorCrypto, err := New([]string{key}, nil, nil, WithX25519Keys(nil, auditorPrivateKey))
+	assert.NoError(t, err)
+	value := &InnerStruct{One: Stringx{Body: "passport"}}
+	assert.NoError(t, crypto.Encrypt(value))
+	assert.Equal(t, AlgorithmRecipients, value.One.PublicKeyAlgorithm)
+	report, err := crypto.Inspect(value)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user", "compliance"}, report.Fields[0].Recipients)
+	// every recipient decrypts independently
+	decrypt := func(c Crypto, value *InnerStruct) (string, error) {
+		cp := InnerStruct{One: value.One}
+		cp.One.WrappedKeys = append([]WrappedKey(nil), value.One.WrappedKeys...)
+		err := c.Decrypt(&cp)
+		return cp.One.Body, err
+	}
+	for _, c := range []Crypto{userCrypto, complianceCrypto} {
+		body, err := decrypt(c, value)
+		assert.NoError(t, err)
+		assert.Equal(t, "passport", body)
+	}
+	_, err = decrypt(auditorCrypto, value)
+	assert.ErrorIs(t, err, ErrNotRecipient)
+	// recipients are added and revoked without encrypting the body again
+	body := value.One.Body
+	assert.Error(t, auditorCrypto.(RecipientCrypto).AddRecipients(value, Recipient{ID: "auditor", PublicKey: auditorPublicKey}))
+	assert.Error(t, userCrypto.(RecipientCrypto).AddRecipients(value, Recipient{ID: "auditor", PublicKey: signingPublicKey}))
+	assert.Len(t, value.One.WrappedKeys, 2)
+	assert.NoError(t, userCrypto.(RecipientCrypto).AddRecipients(value, Recipient{ID: "auditor", PublicKey: auditorPublicKey}))
+	assert.NoError(t, crypto.(RecipientCrypto).RevokeRecipients(value, "compliance"))
+	assert.Equal(t, body, value.One.Body)
+	decrypted, err := decrypt(auditorCrypto, value)
+	assert.NoError(t, err)
+	assert.Equal(t, "passport", decrypted)
+	_, err = decrypt(complianceCrypto, value)
+	assert.ErrorIs(t, err, ErrNotRecipient)
+	// revoking fails without changing any value if one would lose its last recipient
+	userOnlyCrypto, err := New([]string{key}, nil, nil, WithRecipients(Recipient{ID: "user", PublicKey: userPublicKey}))
+	assert.NoError(t, err)
+	values := []*InnerStruct{value, {One: Stringx{Body: "visa"}}}
+	assert.NoError(t, userOnlyCrypto.Encrypt(values[1]))
+	assert.Error(t, crypto.(RecipientCrypto).RevokeRecipients(&values, "user"))
+	assert.Len(t, value.One.WrappedKeys, 2)
+	assert.Len(t, values[1].One.WrappedKeys, 1)
+	assert.Error(t, crypto.(RecipientCrypto).RevokeRecipients(value, "user", "auditor"))
+	assert.Len(t, value.One.WrappedKeys, 2)
+	// adding fails without changing any value if one of the content keys cannot be unwrapped
+	complianceOnlyCrypto, err := New([]string{key}, nil, nil, WithRecipients(Recipient{ID: "compliance", PublicKey: compliancePublicKey}))
+	assert.NoError(t, err)
+	values = []*InnerStruct{value, {One: Stringx{Body: "visa"}}}
+	assert.NoError(t, complianceOnlyCrypto.Encrypt(values[1]))
+	assert.ErrorIs(t, userCrypto.(RecipientCrypto).AddRecipients(&values, Recipient{ID: "compliance", PublicKey: compliancePublicKey}), ErrNotRecipient)
+	assert.Len(t, value.One.WrappedKeys, 2)
+	assert.Len(t, values[1].One.WrappedKeys, 1)
+	assert.NoError(t, crypto.Close())
+	assert.ErrorIs(t, crypto.(RecipientCrypto).RevokeRecipients(value, "user"), ErrClosed)
+}
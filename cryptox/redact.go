@@ -0,0 +1,81 @@
+package cryptox
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redactionKey keys the hash shown for redacted bodies. It is random per process, so a hash can correlate
+// equal values within the logs of one process but cannot be used to guess a body offline.
+var redactionKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// Format implements fmt.Formatter so printing a Stringx with any verb shows its length, level and a short
+// hash instead of the body. Build with the cryptox_debug tag to print the body while debugging.
+func (stringx Stringx) Format(f fmt.State, verb rune) {
+	if !redactStringx {
+		// plain has no Format method, so it is printed like any struct
+		type plain Stringx
+		switch {
+		case f.Flag('#'):
+			fmt.Fprintf(f, "%#v", plain(stringx))
+		case f.Flag('+'):
+			fmt.Fprintf(f, "%+v", plain(stringx))
+		default:
+			fmt.Fprintf(f, "%v", plain(stringx))
+		}
+		return
+	}
+	io.WriteString(f, stringx.redacted())
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler with the same redaction as Format.
+func (stringx Stringx) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if !redactStringx {
+		enc.AddString("body", stringx.Body)
+	} else if stringx.Body != "" {
+		enc.AddString("hash", stringx.bodyHash())
+	}
+	enc.AddInt("len", len(stringx.Body))
+	enc.AddInt32("level", stringx.EncryptionLevel)
+	enc.AddBool("public_key_encrypted", stringx.PublicKeyEncrypted)
+	return nil
+}
+
+// ZapStringx returns a zap field logging a redacted Stringx, a nil Stringx is logged as nil.
+func ZapStringx(key string, stringx *Stringx) zap.Field {
+	if stringx == nil {
+		return zap.Reflect(key, nil)
+	}
+	return zap.Object(key, *stringx)
+}
+
+func (stringx Stringx) redacted() string {
+	redacted := "Stringx{len:" + strconv.Itoa(len(stringx.Body)) + " level:" + strconv.Itoa(int(stringx.EncryptionLevel))
+	if stringx.PublicKeyEncrypted {
+		redacted += " public_key_encrypted:true"
+	}
+	if stringx.Body != "" {
+		redacted += " hash:" + stringx.bodyHash()
+	}
+	return redacted + "}"
+}
+
+func (stringx Stringx) bodyHash() string {
+	mac := hmac.New(sha256.New, redactionKey)
+	mac.Write([]byte(stringx.Body))
+	return hex.EncodeToString(mac.Sum(nil)[:4])
+}
@@ -0,0 +1,6 @@
+//go:build cryptox_debug
+
+package cryptox
+
+// redactStringx is disabled by the cryptox_debug build tag, never ship a build with it.
+const redactStringx = false
@@ -0,0 +1,6 @@
+//go:build !cryptox_debug
+
+package cryptox
+
+// redactStringx hides the body of a Stringx in fmt and logs, see Stringx.Format.
+const redactStringx = true
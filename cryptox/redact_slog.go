@@ -0,0 +1,20 @@
+//go:build go1.21
+
+package cryptox
+
+import "log/slog"
+
+// LogValue implements slog.LogValuer with the same redaction as Format.
+func (stringx Stringx) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int("len", len(stringx.Body)),
+		slog.Int("level", int(stringx.EncryptionLevel)),
+		slog.Bool("public_key_encrypted", stringx.PublicKeyEncrypted),
+	}
+	if !redactStringx {
+		attrs = append(attrs, slog.String("body", stringx.Body))
+	} else if stringx.Body != "" {
+		attrs = append(attrs, slog.String("hash", stringx.bodyHash()))
+	}
+	return slog.GroupValue(attrs...)
+}
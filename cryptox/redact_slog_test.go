@@ -0,0 +1,22 @@
+//go:build go1.21
+
+package cryptox
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactionSlog(t *testing.T) {
+	if !redactStringx {
+		t.Skip("redaction is disabled by the cryptox_debug tag")
+	}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("user", "email", Stringx{Body: "test@example.com", EncryptionLevel: 1})
+	assert.NotContains(t, buf.String(), "test@example.com")
+	assert.Contains(t, buf.String(), `"email":{"len":16,"level":1,"public_key_encrypted":false,"hash":"`)
+}
@@ -0,0 +1,40 @@
+package cryptox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedaction(t *testing.T) {
+	if !redactStringx {
+		t.Skip("redaction is disabled by the cryptox_debug tag")
+	}
+	secret := Stringx{Body: "test@example.com", EncryptionLevel: 1}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%d"} {
+		for _, value := range []interface{}{secret, &secret, struct{ Email Stringx }{secret}} {
+			printed := fmt.Sprintf(format, value)
+			assert.NotContains(t, printed, "test@example.com", format)
+			assert.NotContains(t, printed, fmt.Sprintf("%x", "test@example.com"), format)
+		}
+	}
+	printed := fmt.Sprint(secret)
+	assert.True(t, strings.HasPrefix(printed, "Stringx{len:16 level:1 hash:"), printed)
+	// equal bodies have equal hashes
+	assert.Equal(t, printed, fmt.Sprint(Stringx{Body: "test@example.com", EncryptionLevel: 1}))
+	assert.NotEqual(t, printed, fmt.Sprint(Stringx{Body: "other@example.com", EncryptionLevel: 1}))
+	assert.Equal(t, "Stringx{len:0 level:0}", fmt.Sprint(Stringx{}))
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+	logger.Info("user", ZapStringx("email", &secret), zap.Any("any", secret), ZapStringx("nil", nil))
+	fields := logs.All()[0].ContextMap()
+	assert.NotContains(t, fmt.Sprint(fields), "test@example.com")
+	assert.Equal(t, 16, fields["email"].(map[string]interface{})["len"])
+	assert.Equal(t, fields["email"], fields["any"])
+	assert.Nil(t, fields["nil"])
+}
@@ -0,0 +1,195 @@
+package cryptox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrVersionConflict is returned by ReencryptCollection.Update when the document was changed since it was scanned.
+	ErrVersionConflict = errors.New("document was changed concurrently")
+)
+
+// ReencryptDocument is a document scanned by a ReencryptJob. Value is a pointer to the decoded document.
+type ReencryptDocument struct {
+	ID interface{}
+	// Version is used for optimistic concurrency, the document is only updated if it still has this version
+	Version int64
+	Value   interface{}
+}
+
+// ReencryptCollection is the collection a ReencryptJob rewrites.
+type ReencryptCollection interface {
+	// Scan returns up to limit documents ordered by id, starting after the id after. A nil after starts at the beginning.
+	Scan(ctx context.Context, after interface{}, limit int) ([]*ReencryptDocument, error)
+	// Update writes the value of doc and increments its version. It fails with ErrVersionConflict
+	// if the stored document no longer has doc.Version.
+	Update(ctx context.Context, doc *ReencryptDocument) error
+}
+
+// ReencryptCheckpoints stores how far a ReencryptJob got, so it resumes after a restart.
+type ReencryptCheckpoints interface {
+	// Load returns the id of the last processed document of job, or nil if the job has not started.
+	Load(ctx context.Context, job string) (interface{}, error)
+	Save(ctx context.Context, job string, lastID interface{}) error
+	Delete(ctx context.Context, job string) error
+}
+
+// ReencryptProgress is reported after every batch and when the job finishes.
+type ReencryptProgress struct {
+	Scanned     int
+	Reencrypted int
+	// Conflicts counts documents changed while they were re-encrypted. They are skipped and picked up by the next run if still stale.
+	Conflicts int
+	LastID    interface{}
+	Done      bool
+}
+
+// ReencryptOption configures a ReencryptJob.
+type ReencryptOption func(j *ReencryptJob)
+
+// WithReencryptBatchSize sets how many documents are scanned at once, it defaults to 100.
+func WithReencryptBatchSize(batchSize int) ReencryptOption {
+	return func(j *ReencryptJob) {
+		j.batchSize = batchSize
+	}
+}
+
+// WithReencryptPause throttles the job by pausing between batches.
+func WithReencryptPause(pause time.Duration) ReencryptOption {
+	return func(j *ReencryptJob) {
+		j.pause = pause
+	}
+}
+
+// WithReencryptProgress calls progress after every batch.
+func WithReencryptProgress(progress func(ReencryptProgress)) ReencryptOption {
+	return func(j *ReencryptJob) {
+		j.progress = progress
+	}
+}
+
+// WithReencryptCheckpoints saves the last processed id after every batch and resumes from it.
+// The checkpoint is deleted when the job finishes, so the next run scans the whole collection again.
+func WithReencryptCheckpoints(checkpoints ReencryptCheckpoints) ReencryptOption {
+	return func(j *ReencryptJob) {
+		j.checkpoints = checkpoints
+	}
+}
+
+// ReencryptJob rewrites the documents of a collection that Upgradeble reports, eg. after a key level was added.
+type ReencryptJob struct {
+	name        string
+	crypto      Crypto
+	collection  ReencryptCollection
+	batchSize   int
+	pause       time.Duration
+	progress    func(ReencryptProgress)
+	checkpoints ReencryptCheckpoints
+}
+
+// NewReencryptJob creates a job which decrypts and encrypts stale documents of collection with crypto.
+// name identifies the checkpoint of the job.
+func NewReencryptJob(name string, crypto Crypto, collection ReencryptCollection, opts ...ReencryptOption) *ReencryptJob {
+	j := &ReencryptJob{
+		name:       name,
+		crypto:     crypto,
+		collection: collection,
+		batchSize:  100,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// Run scans the collection until it is done or ctx is cancelled. A cancelled job resumes from its checkpoint.
+func (j *ReencryptJob) Run(ctx context.Context) (*ReencryptProgress, error) {
+	if j.batchSize <= 0 {
+		return nil, errors.New("batch size must be positive")
+	}
+	progress := &ReencryptProgress{}
+	if j.checkpoints != nil {
+		lastID, err := j.checkpoints.Load(ctx, j.name)
+		if err != nil {
+			return nil, fmt.Errorf("load checkpoint: %w", err)
+		}
+		progress.LastID = lastID
+	}
+	for {
+		docs, err := j.collection.Scan(ctx, progress.LastID, j.batchSize)
+		if err != nil {
+			return progress, fmt.Errorf("scan: %w", err)
+		}
+		for _, doc := range docs {
+			if err := j.reencrypt(ctx, doc, progress); err != nil {
+				return progress, fmt.Errorf("document %v: %w", doc.ID, err)
+			}
+			progress.Scanned++
+			progress.LastID = doc.ID
+		}
+		if len(docs) < j.batchSize {
+			break
+		}
+		if j.checkpoints != nil {
+			if err := j.checkpoints.Save(ctx, j.name, progress.LastID); err != nil {
+				return progress, fmt.Errorf("save checkpoint: %w", err)
+			}
+		}
+		j.report(progress)
+		if err := sleep(ctx, j.pause); err != nil {
+			return progress, err
+		}
+	}
+	if j.checkpoints != nil {
+		if err := j.checkpoints.Delete(ctx, j.name); err != nil {
+			return progress, fmt.Errorf("delete checkpoint: %w", err)
+		}
+	}
+	progress.Done = true
+	j.report(progress)
+	return progress, nil
+}
+
+func (j *ReencryptJob) reencrypt(ctx context.Context, doc *ReencryptDocument, progress *ReencryptProgress) error {
+	upgradable, err := j.crypto.Upgradeble(doc.Value)
+	if err != nil || !upgradable {
+		return err
+	}
+	if err := j.crypto.Decrypt(doc.Value); err != nil {
+		return err
+	}
+	if err := j.crypto.Encrypt(doc.Value); err != nil {
+		return err
+	}
+	if err := j.collection.Update(ctx, doc); errors.Is(err, ErrVersionConflict) {
+		progress.Conflicts++
+		return nil
+	} else if err != nil {
+		return err
+	}
+	progress.Reencrypted++
+	return nil
+}
+
+func (j *ReencryptJob) report(progress *ReencryptProgress) {
+	if j.progress != nil {
+		j.progress(*progress)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+package cryptox
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// conflictingCollection changes a document right before the job updates it.
+type conflictingCollection struct {
+	*MemoryReencryptCollection
+	conflictID string
+}
+
+func (c *conflictingCollection) Update(ctx context.Context, doc *ReencryptDocument) error {
+	if doc.ID == c.conflictID {
+		// another writer stores the document again, still at the old level
+		stored := &note{}
+		if _, err := c.Get(c.conflictID, stored); err != nil {
+			return err
+		}
+		if err := c.Put(c.conflictID, stored); err != nil {
+			return err
+		}
+	}
+	return c.MemoryReencryptCollection.Update(ctx, doc)
+}
+
+func TestReencryptJob(t *testing.T) {
+	keyOne, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	keyTwo, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	oldCrypto, err := New([]string{keyOne}, nil, nil)
+	assert.NoError(t, err)
+	newCrypto, err := New([]string{keyOne, keyTwo}, nil, nil)
+	assert.NoError(t, err)
+	newNote := func() interface{} { return &note{} }
+	collection := NewMemoryReencryptCollection(newNote)
+	for i := 0; i < 25; i++ {
+		value := &note{Title: Stringx{Body: fmt.Sprintf("title %d", i)}, Body: Stringx{Body: "body"}}
+		assert.NoError(t, oldCrypto.Encrypt(value))
+		assert.NoError(t, collection.Put(fmt.Sprintf("note-%02d", i), value))
+	}
+	checkpoints := NewMemoryReencryptCheckpoints()
+	// the first run is cancelled after the second batch
+	ctx, cancel := context.WithCancel(context.Background())
+	var reports []ReencryptProgress
+	job := NewReencryptJob("notes", newCrypto, collection,
+		WithReencryptBatchSize(10),
+		WithReencryptCheckpoints(checkpoints),
+		WithReencryptProgress(func(progress ReencryptProgress) {
+			reports = append(reports, progress)
+			if len(reports) == 2 {
+				cancel()
+			}
+		}),
+	)
+	progress, err := job.Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 20, progress.Reencrypted)
+	assert.False(t, progress.Done)
+	assert.Len(t, reports, 2)
+	assert.Equal(t, "note-09", reports[0].LastID)
+	lastID, err := checkpoints.Load(context.Background(), "notes")
+	assert.NoError(t, err)
+	assert.Equal(t, "note-19", lastID)
+	// the second run resumes from the checkpoint, one document is changed concurrently
+	conflicting := &conflictingCollection{MemoryReencryptCollection: collection, conflictID: "note-22"}
+	progress, err = NewReencryptJob("notes", newCrypto, conflicting,
+		WithReencryptBatchSize(10),
+		WithReencryptCheckpoints(checkpoints),
+	).Run(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, progress.Done)
+	assert.Equal(t, 5, progress.Scanned)
+	assert.Equal(t, 4, progress.Reencrypted)
+	assert.Equal(t, 1, progress.Conflicts)
+	lastID, err = checkpoints.Load(context.Background(), "notes")
+	assert.NoError(t, err)
+	assert.Nil(t, lastID)
+	// the conflicting document is picked up by the next run
+	progress, err = NewReencryptJob("notes", newCrypto, collection).Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 25, progress.Scanned)
+	assert.Equal(t, 1, progress.Reencrypted)
+	for i := 0; i < 25; i++ {
+		value := &note{}
+		_, err := collection.Get(fmt.Sprintf("note-%02d", i), value)
+		assert.NoError(t, err)
+		upgradable, err := newCrypto.Upgradeble(value)
+		assert.NoError(t, err)
+		assert.False(t, upgradable)
+		assert.NoError(t, newCrypto.Decrypt(value))
+		assert.Equal(t, fmt.Sprintf("title %d", i), value.Title.Body)
+	}
+}
@@ -0,0 +1,152 @@
+package cryptox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type memoryDocument struct {
+	data    []byte
+	version int64
+}
+
+// MemoryReencryptCollection is a ReencryptCollection which keeps JSON encoded documents with string ids in memory.
+// Useful for tests and for documents loaded from files.
+type MemoryReencryptCollection struct {
+	mu       sync.RWMutex
+	docs     map[string]*memoryDocument
+	newValue func() interface{}
+}
+
+// NewMemoryReencryptCollection returns an empty collection. newValue returns a pointer to a new document
+// which scanned documents are decoded into.
+func NewMemoryReencryptCollection(newValue func() interface{}) *MemoryReencryptCollection {
+	return &MemoryReencryptCollection{
+		docs:     map[string]*memoryDocument{},
+		newValue: newValue,
+	}
+}
+
+// Put stores value under id and increments its version.
+func (c *MemoryReencryptCollection) Put(id string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.docs[id]
+	if !ok {
+		doc = &memoryDocument{}
+		c.docs[id] = doc
+	}
+	doc.data = data
+	doc.version++
+	return nil
+}
+
+// Get decodes the document with id into value and returns its version.
+func (c *MemoryReencryptCollection) Get(id string, value interface{}) (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	doc, ok := c.docs[id]
+	if !ok {
+		return 0, fmt.Errorf("document %s not found", id)
+	}
+	return doc.version, json.Unmarshal(doc.data, value)
+}
+
+func (c *MemoryReencryptCollection) Scan(ctx context.Context, after interface{}, limit int) ([]*ReencryptDocument, error) {
+	afterID := ""
+	if after != nil {
+		var ok bool
+		if afterID, ok = after.(string); !ok {
+			return nil, fmt.Errorf("invalid id type %T", after)
+		}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ids := make([]string, 0, len(c.docs))
+	for id := range c.docs {
+		if after == nil || id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	docs := make([]*ReencryptDocument, 0, len(ids))
+	for _, id := range ids {
+		value := c.newValue()
+		if err := json.Unmarshal(c.docs[id].data, value); err != nil {
+			return nil, err
+		}
+		docs = append(docs, &ReencryptDocument{
+			ID:      id,
+			Version: c.docs[id].version,
+			Value:   value,
+		})
+	}
+	return docs, nil
+}
+
+func (c *MemoryReencryptCollection) Update(ctx context.Context, doc *ReencryptDocument) error {
+	id, ok := doc.ID.(string)
+	if !ok {
+		return fmt.Errorf("invalid id type %T", doc.ID)
+	}
+	data, err := json.Marshal(doc.Value)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stored, ok := c.docs[id]
+	if !ok {
+		return errors.New("document not found")
+	}
+	if stored.version != doc.Version {
+		return ErrVersionConflict
+	}
+	stored.data = data
+	stored.version++
+	doc.Version = stored.version
+	return nil
+}
+
+type memoryReencryptCheckpoints struct {
+	mu          sync.Mutex
+	checkpoints map[string]interface{}
+}
+
+// NewMemoryReencryptCheckpoints returns ReencryptCheckpoints kept in memory.
+func NewMemoryReencryptCheckpoints() ReencryptCheckpoints {
+	return &memoryReencryptCheckpoints{
+		checkpoints: map[string]interface{}{},
+	}
+}
+
+func (s *memoryReencryptCheckpoints) Load(ctx context.Context, job string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpoints[job], nil
+}
+
+func (s *memoryReencryptCheckpoints) Save(ctx context.Context, job string, lastID interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[job] = lastID
+	return nil
+}
+
+func (s *memoryReencryptCheckpoints) Delete(ctx context.Context, job string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.checkpoints, job)
+	return nil
+}
@@ -0,0 +1,135 @@
+package cryptox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoReencryptCollection struct {
+	collection   *mongo.Collection
+	newValue     func() interface{}
+	versionField string
+}
+
+// NewMongoReencryptCollection returns a ReencryptCollection for a Mongo collection. newValue returns a pointer to a
+// new document which scanned documents are decoded into. versionField holds the version used for optimistic
+// concurrency, a missing field is version 0, and writers of the collection must increment it on every update.
+// Documents are encoded with the default registry, so a client registry from NewBSONRegistry is bypassed.
+func NewMongoReencryptCollection(collection *mongo.Collection, newValue func() interface{}, versionField string) ReencryptCollection {
+	return &mongoReencryptCollection{
+		collection:   collection,
+		newValue:     newValue,
+		versionField: versionField,
+	}
+}
+
+func (c *mongoReencryptCollection) Scan(ctx context.Context, after interface{}, limit int) ([]*ReencryptDocument, error) {
+	filter := bson.M{}
+	if after != nil {
+		filter["_id"] = bson.M{"$gt": after}
+	}
+	cursor, err := c.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var docs []*ReencryptDocument
+	for cursor.Next(ctx) {
+		doc := &ReencryptDocument{Value: c.newValue()}
+		if err := cursor.Current.Lookup("_id").Unmarshal(&doc.ID); err != nil {
+			return nil, err
+		}
+		if version, err := cursor.Current.LookupErr(c.versionField); err == nil {
+			var ok bool
+			if doc.Version, ok = version.AsInt64OK(); !ok {
+				return nil, errors.New("version is not a number")
+			}
+		}
+		if err := bson.Unmarshal(cursor.Current, doc.Value); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, cursor.Err()
+}
+
+func (c *mongoReencryptCollection) Update(ctx context.Context, doc *ReencryptDocument) error {
+	raw, err := bson.Marshal(doc.Value)
+	if err != nil {
+		return err
+	}
+	elements, err := bson.Raw(raw).Elements()
+	if err != nil {
+		return err
+	}
+	// only the fields of the value are set, so fields unknown to the value are kept
+	set := bson.D{}
+	for _, element := range elements {
+		if key := element.Key(); key != "_id" && key != c.versionField {
+			set = append(set, bson.E{Key: key, Value: element.Value()})
+		}
+	}
+	set = append(set, bson.E{Key: c.versionField, Value: doc.Version + 1})
+	filter := bson.M{"_id": doc.ID, c.versionField: doc.Version}
+	if doc.Version == 0 {
+		filter = bson.M{"_id": doc.ID, "$or": bson.A{
+			bson.M{c.versionField: bson.M{"$exists": false}},
+			bson.M{c.versionField: 0},
+		}}
+	}
+	result, err := c.collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrVersionConflict
+	}
+	doc.Version++
+	return nil
+}
+
+type reencryptCheckpointDocument struct {
+	Job       string      `bson:"_id"`
+	LastID    interface{} `bson:"last_id"`
+	UpdatedAt time.Time   `bson:"updated_at"`
+}
+
+type mongoReencryptCheckpoints struct {
+	collection *mongo.Collection
+}
+
+// NewMongoReencryptCheckpoints returns ReencryptCheckpoints stored in a collection with one document per job.
+func NewMongoReencryptCheckpoints(collection *mongo.Collection) ReencryptCheckpoints {
+	return &mongoReencryptCheckpoints{
+		collection: collection,
+	}
+}
+
+func (s *mongoReencryptCheckpoints) Load(ctx context.Context, job string) (interface{}, error) {
+	doc := &reencryptCheckpointDocument{}
+	if err := s.collection.FindOne(ctx, bson.M{"_id": job}).Decode(doc); errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return doc.LastID, nil
+}
+
+func (s *mongoReencryptCheckpoints) Save(ctx context.Context, job string, lastID interface{}) error {
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": job}, &reencryptCheckpointDocument{
+		Job:       job,
+		LastID:    lastID,
+		UpdatedAt: time.Now(),
+	}, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *mongoReencryptCheckpoints) Delete(ctx context.Context, job string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": job})
+	return err
+}
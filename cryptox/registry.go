@@ -0,0 +1,306 @@
+package cryptox
+
+import (
+	"container/list"
+	"context"
+	"crypto/rsa"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TenantKeys are the keys of a single tenant as returned by a KeyLoader.
+type TenantKeys struct {
+	SymmetricKeys []string
+	PublicKey     *rsa.PublicKey
+	PrivateKey    *rsa.PrivateKey
+	// Options are applied after the options of the Registry
+	Options []Option
+}
+
+// KeyLoader loads the keys of a tenant, eg. from a secret manager or a database.
+type KeyLoader interface {
+	LoadKeys(ctx context.Context, tenantID string) (*TenantKeys, error)
+}
+
+// KeyLoaderFunc turns a function into a KeyLoader.
+type KeyLoaderFunc func(ctx context.Context, tenantID string) (*TenantKeys, error)
+
+func (f KeyLoaderFunc) LoadKeys(ctx context.Context, tenantID string) (*TenantKeys, error) {
+	return f(ctx, tenantID)
+}
+
+// RegistryOption configures a Registry.
+type RegistryOption func(r *Registry)
+
+// WithTTL sets how long a tenant is cached before its keys are loaded again. Zero disables expiry.
+func WithTTL(ttl time.Duration) RegistryOption {
+	return func(r *Registry) {
+		r.ttl = ttl
+	}
+}
+
+// WithMaxTenants sets how many tenants are cached before the least recently used is evicted. Zero disables the limit.
+func WithMaxTenants(maxTenants int) RegistryOption {
+	return func(r *Registry) {
+		r.maxTenants = maxTenants
+	}
+}
+
+// WithTenantOptions sets options applied to every Crypto created by the Registry.
+func WithTenantOptions(opts ...Option) RegistryOption {
+	return func(r *Registry) {
+		r.options = append(r.options, opts...)
+	}
+}
+
+type registryEntry struct {
+	tenantID  string
+	crypto    *defaultCrypto
+	expiresAt time.Time
+	// refs counts the leases handed out by Get which have not been released
+	refs int
+	// evicted is set when the entry left the cache and closed once its keys are wiped
+	evicted bool
+	closed  bool
+}
+
+// registryLoad is a load in progress which concurrent lookups of the same tenant wait for.
+type registryLoad struct {
+	done  chan struct{}
+	entry *registryEntry
+	err   error
+}
+
+// Registry resolves a Crypto per tenant through a KeyLoader and caches it. Get hands out the Crypto together
+// with a release function. Keys of evicted and expired tenants are wiped once every lease is released, after
+// which the Crypto returns ErrClosed - callers should call Get for each unit of work rather than keep the Crypto around.
+type Registry struct {
+	loader     KeyLoader
+	ttl        time.Duration
+	maxTenants int
+	options    []Option
+	now        func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*list.Element
+	lru       *list.List
+	loads     map[string]*registryLoad
+	nextSweep time.Time
+	// evicted holds removed instances which are wiped once mu is released
+	evicted []*defaultCrypto
+	closed  bool
+}
+
+func NewRegistry(loader KeyLoader, opts ...RegistryOption) *Registry {
+	r := &Registry{
+		loader:  loader,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		loads:   map[string]*registryLoad{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Get returns the Crypto of tenantID, loading its keys if it is not cached. The Crypto stays usable until
+// release is called, even if the tenant is evicted in the meantime. release must be called exactly once
+// per successful Get, further calls are ignored. Concurrent lookups of a tenant share one load with the ctx of
+// the first lookup; if that ctx ends, the others load the keys again with their own ctx.
+func (r *Registry) Get(ctx context.Context, tenantID string) (Crypto, func(), error) {
+	if tenantID == "" {
+		return nil, nil, errors.New("tenant id is empty")
+	}
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return nil, nil, ErrClosed
+		}
+		r.removeExpired()
+		if element, ok := r.entries[tenantID]; ok {
+			entry := element.Value.(*registryEntry)
+			if r.ttl <= 0 || r.now().Before(entry.expiresAt) {
+				r.lru.MoveToFront(element)
+				release := r.lease(entry)
+				r.unlock()
+				return entry.crypto, release, nil
+			}
+			r.remove(element)
+		}
+		load, loading := r.loads[tenantID]
+		if !loading {
+			load = &registryLoad{done: make(chan struct{})}
+			r.loads[tenantID] = load
+		}
+		r.unlock()
+		if loading {
+			select {
+			case <-load.done:
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+			if isContextError(load.err) && ctx.Err() == nil {
+				// the load ended with the ctx of another lookup, this one has not ended yet
+				continue
+			}
+			if load.err != nil {
+				return nil, nil, load.err
+			}
+			r.mu.Lock()
+			if load.entry.closed {
+				// the tenant was evicted and wiped before this lookup got a lease - look it up again
+				r.unlock()
+				continue
+			}
+			release := r.lease(load.entry)
+			r.unlock()
+			return load.entry.crypto, release, nil
+		}
+		loaded, err := r.load(ctx, tenantID)
+		r.mu.Lock()
+		delete(r.loads, tenantID)
+		if err == nil && r.closed {
+			r.evicted = append(r.evicted, loaded)
+			err = ErrClosed
+		}
+		var release func()
+		if err == nil {
+			load.entry = r.add(tenantID, loaded)
+			release = r.lease(load.entry)
+		}
+		load.err = err
+		r.unlock()
+		close(load.done)
+		if err != nil {
+			return nil, nil, err
+		}
+		return loaded, release, nil
+	}
+}
+
+// Evict removes tenantID from the cache, so the next Get loads its keys again. The keys are wiped once every lease is released.
+func (r *Registry) Evict(tenantID string) {
+	r.mu.Lock()
+	defer r.unlock()
+	if element, ok := r.entries[tenantID]; ok {
+		r.remove(element)
+	}
+}
+
+// Len returns the number of cached tenants.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lru.Len()
+}
+
+// Close evicts every tenant. Get returns ErrClosed afterwards and keys are wiped once every lease is released.
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.unlock()
+	r.closed = true
+	for r.lru.Len() > 0 {
+		r.remove(r.lru.Back())
+	}
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (r *Registry) load(ctx context.Context, tenantID string) (*defaultCrypto, error) {
+	keys, err := r.loader.LoadKeys(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		return nil, errors.New("key loader returned no keys")
+	}
+	crypto, err := New(keys.SymmetricKeys, keys.PublicKey, keys.PrivateKey, append(append([]Option{}, r.options...), keys.Options...)...)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.(*defaultCrypto), nil
+}
+
+// add must be called with r.mu held.
+func (r *Registry) add(tenantID string, crypto *defaultCrypto) *registryEntry {
+	entry := &registryEntry{
+		tenantID: tenantID,
+		crypto:   crypto,
+	}
+	if r.ttl > 0 {
+		entry.expiresAt = r.now().Add(r.ttl)
+	}
+	r.entries[tenantID] = r.lru.PushFront(entry)
+	for r.maxTenants > 0 && r.lru.Len() > r.maxTenants {
+		r.remove(r.lru.Back())
+	}
+	return entry
+}
+
+// lease hands out a reference to entry and returns the function releasing it. It must be called with r.mu held.
+func (r *Registry) lease(entry *registryEntry) func() {
+	entry.refs++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.unlock()
+			entry.refs--
+			if entry.evicted && entry.refs == 0 {
+				r.close(entry)
+			}
+		})
+	}
+}
+
+// unlock releases r.mu and wipes the keys of evicted tenants. Wiping waits for running
+// operations of the tenant, so it is done without holding the lock.
+func (r *Registry) unlock() {
+	evicted := r.evicted
+	r.evicted = nil
+	r.mu.Unlock()
+	for _, crypto := range evicted {
+		crypto.Close()
+	}
+}
+
+// removeExpired sweeps expired tenants at most four times per ttl. It must be called with r.mu held.
+func (r *Registry) removeExpired() {
+	if r.ttl <= 0 {
+		return
+	}
+	now := r.now()
+	if now.Before(r.nextSweep) {
+		return
+	}
+	r.nextSweep = now.Add(r.ttl / 4)
+	for element := r.lru.Front(); element != nil; {
+		next := element.Next()
+		if !now.Before(element.Value.(*registryEntry).expiresAt) {
+			r.remove(element)
+		}
+		element = next
+	}
+}
+
+// remove must be called with r.mu held.
+func (r *Registry) remove(element *list.Element) {
+	entry := r.lru.Remove(element).(*registryEntry)
+	delete(r.entries, entry.tenantID)
+	entry.evicted = true
+	if entry.refs == 0 {
+		r.close(entry)
+	}
+}
+
+// close queues the keys of entry to be wiped. It must be called with r.mu held.
+func (r *Registry) close(entry *registryEntry) {
+	entry.closed = true
+	r.evicted = append(r.evicted, entry.crypto)
+}
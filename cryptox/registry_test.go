@@ -0,0 +1,123 @@
+package cryptox
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	tenantKeys := map[string]string{}
+	for _, tenant := range []string{"one", "two", "three"} {
+		key, err := GenerateSymmetricKey(32, AlphaNum)
+		assert.NoError(t, err)
+		tenantKeys[tenant] = key
+	}
+	var loads int32
+	registry := NewRegistry(KeyLoaderFunc(func(ctx context.Context, tenantID string) (*TenantKeys, error) {
+		atomic.AddInt32(&loads, 1)
+		return &TenantKeys{SymmetricKeys: []string{tenantKeys[tenantID]}}, nil
+	}), WithMaxTenants(2), WithTTL(time.Minute))
+	now := time.Now()
+	registry.now = func() time.Time { return now }
+	// concurrent lookups of the same tenant load the keys once
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, release, err := registry.Get(ctx, "one")
+			assert.NoError(t, err)
+			release()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	one, releaseOne, err := registry.Get(ctx, "one")
+	assert.NoError(t, err)
+	value := &InnerStruct{One: Stringx{Body: "one"}}
+	assert.NoError(t, one.Encrypt(value))
+	// tenants are isolated
+	two, releaseTwo, err := registry.Get(ctx, "two")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, two.Decrypt(&InnerStruct{One: value.One}), ErrAuthentication)
+	releaseTwo()
+	// loading a third tenant evicts the least recently used, which stays usable while it is leased
+	_, releaseThree, err := registry.Get(ctx, "three")
+	assert.NoError(t, err)
+	releaseThree()
+	assert.Equal(t, 2, registry.Len())
+	_, releaseTwo, err = registry.Get(ctx, "two")
+	assert.NoError(t, err)
+	releaseTwo()
+	_, releaseThree, err = registry.Get(ctx, "three")
+	assert.NoError(t, err)
+	releaseThree()
+	assert.NoError(t, one.Decrypt(&InnerStruct{One: value.One}))
+	// the keys of the evicted tenant are wiped once its lease is released
+	releaseOne()
+	releaseOne()
+	assert.ErrorIs(t, one.Decrypt(value), ErrClosed)
+	one, releaseOne, err = registry.Get(ctx, "one")
+	assert.NoError(t, err)
+	assert.NoError(t, one.Decrypt(value))
+	assert.Equal(t, "one", value.One.Body)
+	releaseOne()
+	// expired tenants are loaded again
+	loadsBefore := atomic.LoadInt32(&loads)
+	now = now.Add(2 * time.Minute)
+	one, releaseOne, err = registry.Get(ctx, "one")
+	assert.NoError(t, err)
+	assert.Equal(t, loadsBefore+1, atomic.LoadInt32(&loads))
+	assert.Equal(t, 1, registry.Len())
+	// closing the registry wipes leased keys on release
+	registry.Close()
+	assert.NoError(t, one.Encrypt(&InnerStruct{One: Stringx{Body: "one"}}))
+	releaseOne()
+	assert.ErrorIs(t, one.Encrypt(&InnerStruct{One: Stringx{Body: "one"}}), ErrClosed)
+	_, _, err = registry.Get(ctx, "one")
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestRegistryLoadOutlivesCancelledLookup(t *testing.T) {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	started := make(chan struct{})
+	var loads int32
+	registry := NewRegistry(KeyLoaderFunc(func(ctx context.Context, tenantID string) (*TenantKeys, error) {
+		if atomic.AddInt32(&loads, 1) == 1 {
+			// the first load waits until its lookup is cancelled
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &TenantKeys{SymmetricKeys: []string{key}}, nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, _, err := registry.Get(ctx, "one")
+		first <- err
+	}()
+	<-started
+	second := make(chan error)
+	go func() {
+		crypto, release, err := registry.Get(context.Background(), "one")
+		if err == nil {
+			err = crypto.Encrypt(&InnerStruct{One: Stringx{Body: "one"}})
+			release()
+		}
+		second <- err
+	}()
+	// give the second lookup time to wait for the load of the first
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+	assert.NoError(t, <-second)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+}
@@ -0,0 +1,218 @@
+package cryptox
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	shareVersion = 1
+	// shareHeaderSize is the size of version, threshold, index and set id
+	shareHeaderSize = 1 + 1 + 1 + shareSetIDSize
+	shareSetIDSize  = 8
+	// shareChecksumSize is the size of the checksum which detects corrupted or mistyped shares
+	shareChecksumSize = 4
+	// shareTagSize is the size of the hash appended to the key before splitting, which
+	// detects shares that do not belong together or have been forged
+	shareTagSize = 8
+)
+
+var (
+	// ErrInvalidShare is returned when a share cannot be decoded or does not belong to the other shares.
+	ErrInvalidShare = errors.New("invalid share")
+)
+
+// SplitSymmetricKey splits a hex encoded symmetric key into shares, of which any threshold are needed to
+// recombine the key with CombineShares. Fewer shares than threshold reveal nothing about the key.
+func SplitSymmetricKey(symmetricKey string, threshold, shares int) ([]string, error) {
+	key, err := hex.DecodeString(symmetricKey)
+	if err != nil {
+		return nil, err
+	}
+	defer setZero(key)
+	return splitSecret(rand.Reader, key, threshold, shares)
+}
+
+// CombineShares recombines shares created by SplitSymmetricKey into the hex encoded symmetric key,
+// which can be passed to New or SetSymmetricEncryptionKeys.
+func CombineShares(shares []string) (string, error) {
+	key, err := combineShares(shares)
+	if err != nil {
+		return "", err
+	}
+	defer setZero(key)
+	return hex.EncodeToString(key), nil
+}
+
+func splitSecret(random io.Reader, secret []byte, threshold, shares int) ([]string, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("cannot split an empty key")
+	}
+	if threshold < 2 {
+		return nil, errors.New("threshold must be at least 2")
+	}
+	if shares < threshold {
+		return nil, errors.New("shares must be at least the threshold")
+	}
+	if shares > 255 {
+		return nil, errors.New("shares must be at most 255")
+	}
+	setID := make([]byte, shareSetIDSize)
+	if _, err := io.ReadFull(random, setID); err != nil {
+		return nil, err
+	}
+	tag := sha256.Sum256(secret)
+	tagged := append(append(make([]byte, 0, len(secret)+shareTagSize), secret...), tag[:shareTagSize]...)
+	defer setZero(tagged)
+	// one polynomial of degree threshold-1 per byte, the constant term is the byte of the secret
+	coefficients := make([]byte, len(tagged)*(threshold-1))
+	defer setZero(coefficients)
+	if _, err := io.ReadFull(random, coefficients); err != nil {
+		return nil, err
+	}
+	encoded := make([]string, 0, shares)
+	for index := 1; index <= shares; index++ {
+		share := make([]byte, 0, shareHeaderSize+len(tagged)+shareChecksumSize)
+		share = append(share, shareVersion, byte(threshold), byte(index))
+		share = append(share, setID...)
+		for i, b := range tagged {
+			share = append(share, evaluatePolynomial(b, coefficients[i*(threshold-1):(i+1)*(threshold-1)], byte(index)))
+		}
+		checksum := sha256.Sum256(share)
+		share = append(share, checksum[:shareChecksumSize]...)
+		encoded = append(encoded, hex.EncodeToString(share))
+		setZero(share)
+	}
+	return encoded, nil
+}
+
+func combineShares(encoded []string) ([]byte, error) {
+	if len(encoded) == 0 {
+		return nil, fmt.Errorf("%w: no shares", ErrInvalidShare)
+	}
+	shares := make([][]byte, 0, len(encoded))
+	defer func() { setZeroKeys(shares) }()
+	for i, e := range encoded {
+		share, err := decodeShare(e)
+		if err != nil {
+			return nil, fmt.Errorf("share %d: %w", i+1, err)
+		}
+		shares = append(shares, share)
+	}
+	first := shares[0]
+	threshold := int(first[1])
+	for i, share := range shares[1:] {
+		if len(share) != len(first) || !bytes.Equal(share[:2], first[:2]) || !bytes.Equal(share[3:shareHeaderSize], first[3:shareHeaderSize]) {
+			return nil, fmt.Errorf("share %d: %w: belongs to a different split", i+2, ErrInvalidShare)
+		}
+		for _, other := range shares[:i+1] {
+			if share[2] == other[2] {
+				return nil, fmt.Errorf("share %d: %w: duplicate share %d", i+2, ErrInvalidShare, share[2])
+			}
+		}
+	}
+	if len(shares) < threshold {
+		return nil, fmt.Errorf("%w: %d shares given but %d are needed", ErrInvalidShare, len(shares), threshold)
+	}
+	// any threshold shares define the polynomial, extra shares are only checked to belong to the split
+	xs := make([]byte, threshold)
+	for i, share := range shares[:threshold] {
+		xs[i] = share[2]
+	}
+	size := len(first) - shareHeaderSize
+	tagged := make([]byte, size)
+	ys := make([]byte, threshold)
+	defer setZero(ys)
+	for i := range tagged {
+		for j, share := range shares[:threshold] {
+			ys[j] = share[shareHeaderSize+i]
+		}
+		tagged[i] = interpolateAtZero(xs, ys)
+	}
+	secret, tag := tagged[:size-shareTagSize], tagged[size-shareTagSize:]
+	expected := sha256.Sum256(secret)
+	if subtle.ConstantTimeCompare(tag, expected[:shareTagSize]) != 1 {
+		setZero(tagged)
+		return nil, fmt.Errorf("%w: shares do not recombine to a valid key", ErrInvalidShare)
+	}
+	setZero(tag)
+	return secret, nil
+}
+
+func decodeShare(encoded string) ([]byte, error) {
+	share, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidShare, err)
+	}
+	if len(share) < shareHeaderSize+shareTagSize+1+shareChecksumSize {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidShare)
+	}
+	body, checksum := share[:len(share)-shareChecksumSize], share[len(share)-shareChecksumSize:]
+	expected := sha256.Sum256(body)
+	if subtle.ConstantTimeCompare(checksum, expected[:shareChecksumSize]) != 1 {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidShare)
+	}
+	if share[0] != shareVersion {
+		return nil, fmt.Errorf("%w: unknown version %d", ErrInvalidShare, share[0])
+	}
+	if share[1] < 2 || share[2] == 0 {
+		return nil, fmt.Errorf("%w: invalid threshold or index", ErrInvalidShare)
+	}
+	return body, nil
+}
+
+// evaluatePolynomial returns the value at x of the polynomial with the constant term secret and
+// the higher coefficients.
+func evaluatePolynomial(secret byte, coefficients []byte, x byte) byte {
+	// horner's method from the highest coefficient
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coefficients[i]
+	}
+	return gfMul(y, x) ^ secret
+}
+
+// interpolateAtZero returns the constant term of the polynomial through the points xs, ys.
+func interpolateAtZero(xs, ys []byte) byte {
+	var secret byte
+	for i := range xs {
+		// lagrange basis at zero, subtraction is xor in GF(256)
+		basis := byte(1)
+		for j := range xs {
+			if i != j {
+				basis = gfMul(basis, gfMul(xs[j], gfInverse(xs[j]^xs[i])))
+			}
+		}
+		secret ^= gfMul(ys[i], basis)
+	}
+	return secret
+}
+
+// gfMul multiplies in GF(256) with the AES polynomial without branches or table lookups
+// that depend on the values.
+func gfMul(a, b byte) byte {
+	var product byte
+	for i := 0; i < 8; i++ {
+		product ^= -(b & 1) & a
+		// reduce by x^8 + x^4 + x^3 + x + 1 when the high bit is shifted out
+		a = a<<1 ^ -(a>>7)&0x1b
+		b >>= 1
+	}
+	return product
+}
+
+// gfInverse returns the multiplicative inverse in GF(256) as a^254.
+func gfInverse(a byte) byte {
+	result := byte(1)
+	for i := 0; i < 7; i++ {
+		a = gfMul(a, a)
+		result = gfMul(result, a)
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package cryptox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGFArithmetic(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		assert.Equal(t, byte(1), gfMul(byte(a), gfInverse(byte(a))), a)
+	}
+	// 0x53 * 0xca = 0x01 in the AES field
+	assert.Equal(t, byte(0x01), gfMul(0x53, 0xca))
+	assert.Equal(t, byte(0xc1), gfMul(0x57, 0x83))
+}
+
+func TestSplitAndCombineShares(t *testing.T) {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	shares, err := SplitSymmetricKey(key, 3, 5)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 5)
+	// every subset of threshold shares recombines the key
+	for i := 0; i < 5; i++ {
+		for j := i + 1; j < 5; j++ {
+			for k := j + 1; k < 5; k++ {
+				combined, err := CombineShares([]string{shares[k], shares[i], shares[j]})
+				assert.NoError(t, err)
+				assert.Equal(t, key, combined)
+			}
+		}
+	}
+	all, err := CombineShares(shares)
+	assert.NoError(t, err)
+	assert.Equal(t, key, all)
+	// the recombined key feeds into New
+	c, err := New([]string{all}, nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, c.EqualSymmetricKeys([]string{key}))
+	assert.NoError(t, c.SetSymmetricEncryptionKeys([]string{all}))
+}
+
+func TestCombineInvalidShares(t *testing.T) {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	shares, err := SplitSymmetricKey(key, 3, 5)
+	assert.NoError(t, err)
+	other, err := SplitSymmetricKey(key, 3, 5)
+	assert.NoError(t, err)
+	_, err = CombineShares(shares[:2])
+	assert.ErrorIs(t, err, ErrInvalidShare)
+	_, err = CombineShares([]string{shares[0], shares[1], shares[1]})
+	assert.ErrorIs(t, err, ErrInvalidShare)
+	_, err = CombineShares([]string{shares[0], shares[1], other[2]})
+	assert.ErrorIs(t, err, ErrInvalidShare)
+	_, err = CombineShares(nil)
+	assert.ErrorIs(t, err, ErrInvalidShare)
+	// a single changed digit fails the checksum
+	corrupted := []byte(shares[2])
+	if corrupted[30] == '0' {
+		corrupted[30] = '1'
+	} else {
+		corrupted[30] = '0'
+	}
+	_, err = CombineShares([]string{shares[0], shares[1], string(corrupted)})
+	assert.ErrorIs(t, err, ErrInvalidShare)
+	// a share with a valid checksum but altered value fails the key tag
+	forged, err := decodeShare(shares[2])
+	assert.NoError(t, err)
+	forged[shareHeaderSize] ^= 1
+	_, err = CombineShares([]string{shares[0], shares[1], encodeTestShare(forged)})
+	assert.ErrorIs(t, err, ErrInvalidShare)
+	_, err = SplitSymmetricKey(key, 1, 3)
+	assert.Error(t, err)
+	_, err = SplitSymmetricKey(key, 4, 3)
+	assert.Error(t, err)
+	_, err = SplitSymmetricKey(key, 2, 256)
+	assert.Error(t, err)
+}
+
+// encodeTestShare encodes a decoded share body with a valid checksum.
+func encodeTestShare(body []byte) string {
+	checksum := sha256.Sum256(body)
+	return hex.EncodeToString(append(body, checksum[:shareChecksumSize]...))
+}
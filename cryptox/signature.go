@@ -0,0 +1,246 @@
+package cryptox
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+const (
+	SignatureEd25519 = "Ed25519"
+	SignatureRSAPSS  = "RSA-PSS-SHA256"
+	// signatureContext is prepended to the canonical value so signatures cannot be reused by other protocols
+	signatureContext = "cryptox signature v1\n"
+)
+
+var (
+	// ErrInvalidSignature is returned when a signature does not match the value or its key is unknown.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrNoSigningKey is returned by Sign when no key was set with WithSigningKey.
+	ErrNoSigningKey = errors.New("no signing key provided")
+	signatureType   = reflect.TypeOf(Signature{})
+)
+
+// Signer signs values and verifies their signatures. Every Crypto created by New implements it,
+// eg. c.(cryptox.Signer).Sign(record), but Sign needs WithSigningKey.
+type Signer interface {
+	// Sign signs the canonical JSON of val with the signing key.
+	Sign(val interface{}) (*Signature, error)
+	// Verify checks that signature was made over the canonical JSON of val by one of the verification keys.
+	Verify(val interface{}, signature *Signature) error
+}
+
+// Signature is a detached signature of a value which can be stored alongside it. Fields of type Signature
+// or *Signature of a signed struct are left out of the signed value.
+type Signature struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	// Value is the hex encoded signature
+	Value string `json:"value"`
+}
+
+// WithSigningKey sets the ed25519.PrivateKey or *rsa.PrivateKey used by Sign. Sign fails without it, the rsa keys
+// of New encrypt and are never used for signatures, so the signing key must not be the rsa key of New either.
+func WithSigningKey(privateKey crypto.Signer) Option {
+	return func(c *defaultCrypto) {
+		switch privateKey := privateKey.(type) {
+		case ed25519.PrivateKey:
+			c.keys.signingKey = append(ed25519.PrivateKey(nil), privateKey...)
+		case *rsa.PrivateKey:
+			c.keys.signingKey = copyPrivateKey(privateKey)
+		default:
+			c.keys.signingKey = privateKey
+		}
+	}
+}
+
+// WithVerificationKeys adds ed25519.PublicKey or *rsa.PublicKey keys accepted by Verify, for example the keys
+// which signed records before a rotation. The public key of WithSigningKey is always accepted.
+func WithVerificationKeys(publicKeys ...crypto.PublicKey) Option {
+	return func(c *defaultCrypto) {
+		c.keys.verificationKeyList = append(c.keys.verificationKeyList, publicKeys...)
+	}
+}
+
+// setSignatureKeys selects the signing key and indexes the verification keys by key id.
+func (k *keyring) setSignatureKeys() error {
+	publicKeys := append([]crypto.PublicKey(nil), k.verificationKeyList...)
+	if k.signingKey != nil {
+		if _, err := signatureAlgorithm(k.signingKey.Public()); err != nil {
+			return err
+		}
+		if publicKey, ok := k.signingKey.Public().(*rsa.PublicKey); ok && k.publicKey != nil && publicKey.Equal(k.publicKey) {
+			return errors.New("the signing key must not be the rsa encryption key")
+		}
+		publicKeys = append(publicKeys, k.signingKey.Public())
+	}
+	k.verificationKeys = map[string]crypto.PublicKey{}
+	for _, publicKey := range publicKeys {
+		if _, err := signatureAlgorithm(publicKey); err != nil {
+			return err
+		}
+		keyID, err := publicKeyID(publicKey)
+		if err != nil {
+			return err
+		}
+		k.verificationKeys[keyID] = publicKey
+	}
+	return nil
+}
+
+// Sign implements Signer.
+func (c *defaultCrypto) Sign(val interface{}) (*Signature, error) {
+	message, err := signedMessage(val)
+	if err != nil {
+		return nil, err
+	}
+	release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if c.keys.signingKey == nil {
+		return nil, ErrNoSigningKey
+	}
+	publicKey := c.keys.signingKey.Public()
+	algorithm, err := signatureAlgorithm(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := publicKeyID(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	var signature []byte
+	switch algorithm {
+	case SignatureEd25519:
+		signature, err = c.keys.signingKey.Sign(nil, message, crypto.Hash(0))
+	case SignatureRSAPSS:
+		digest := sha256.Sum256(message)
+		signature, err = c.keys.signingKey.Sign(c.random, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{
+		KeyID:     keyID,
+		Algorithm: algorithm,
+		Value:     hex.EncodeToString(signature),
+	}, nil
+}
+
+// Verify implements Signer.
+func (c *defaultCrypto) Verify(val interface{}, signature *Signature) error {
+	if signature == nil {
+		return fmt.Errorf("%w: signature is nil", ErrInvalidSignature)
+	}
+	message, err := signedMessage(val)
+	if err != nil {
+		return err
+	}
+	value, err := hex.DecodeString(signature.Value)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	publicKey, ok := c.keys.verificationKeys[signature.KeyID]
+	if !ok {
+		return fmt.Errorf("%w: unknown key id %q", ErrInvalidSignature, signature.KeyID)
+	}
+	// the algorithm follows from the key, a signature cannot select another one
+	if algorithm, _ := signatureAlgorithm(publicKey); algorithm != signature.Algorithm {
+		return fmt.Errorf("%w: key %s does not use %s", ErrInvalidSignature, signature.KeyID, signature.Algorithm)
+	}
+	switch publicKey := publicKey.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(publicKey, message, value) {
+			return ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if err := rsa.VerifyPSS(publicKey, crypto.SHA256, digest[:], value, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+			return ErrInvalidSignature
+		}
+	}
+	return nil
+}
+
+func signatureAlgorithm(publicKey crypto.PublicKey) (string, error) {
+	switch publicKey.(type) {
+	case ed25519.PublicKey:
+		return SignatureEd25519, nil
+	case *rsa.PublicKey:
+		return SignatureRSAPSS, nil
+	}
+	return "", fmt.Errorf("unsupported signature key type %T", publicKey)
+}
+
+func signedMessage(val interface{}) ([]byte, error) {
+	canonical, err := CanonicalJSON(val)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(signatureContext), canonical...), nil
+}
+
+// CanonicalJSON returns the JSON of val with object keys sorted, no insignificant whitespace and no HTML escaping,
+// so equal values always serialize to the same bytes. Fields of type Signature or *Signature of a struct are left out.
+// Numbers are written as encoding/json writes them, which only differs from RFC 8785 for some floats.
+func CanonicalJSON(val interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(withoutSignatures(val))
+	if err != nil {
+		return nil, err
+	}
+	// decoding into interface{} turns structs into maps, which are encoded with sorted keys
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(generic); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// withoutSignatures returns a copy of the struct val points to with its signature fields zeroed.
+func withoutSignatures(val interface{}) interface{} {
+	v := reflect.ValueOf(val)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return val
+	}
+	cp := reflect.New(v.Type()).Elem()
+	cp.Set(v)
+	found := false
+	for i := 0; i < cp.NumField(); i++ {
+		fieldType := cp.Type().Field(i).Type
+		if fieldType == signatureType || fieldType.Kind() == reflect.Ptr && fieldType.Elem() == signatureType {
+			if cp.Field(i).CanSet() {
+				cp.Field(i).Set(reflect.Zero(fieldType))
+				found = true
+			}
+		}
+	}
+	if !found {
+		return val
+	}
+	return cp.Interface()
+}
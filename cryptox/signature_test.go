@@ -0,0 +1,88 @@
+package cryptox
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type consentRecord struct {
+	UserID    string            `json:"user_id"`
+	Purpose   string            `json:"purpose"`
+	Granted   bool              `json:"granted"`
+	Details   map[string]string `json:"details"`
+	Signature *Signature        `json:"signature,omitempty"`
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	canonical, err := CanonicalJSON(&consentRecord{
+		UserID:    "user-1",
+		Purpose:   "<marketing>",
+		Details:   map[string]string{"b": "2", "a": "1"},
+		Signature: &Signature{Value: "ignored"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"details":{"a":"1","b":"2"},"granted":false,"purpose":"<marketing>","user_id":"user-1"}`, string(canonical))
+	canonical, err = CanonicalJSON(map[string]interface{}{"z": 1.5, "a": []int{3, 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":[3,1],"z":1.5}`, string(canonical))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	privateKey, publicKey, err := GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	// the rsa encryption key of New never signs
+	encryptionKey, encryptionPublicKey, err := GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	encryptOnly, err := New([]string{key}, encryptionPublicKey, encryptionKey)
+	assert.NoError(t, err)
+	_, err = encryptOnly.(Signer).Sign(&consentRecord{})
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+	_, err = New([]string{key}, encryptionPublicKey, encryptionKey, WithSigningKey(encryptionKey))
+	assert.Error(t, err)
+	// rsa signing keys sign with RSA-PSS
+	rsaCrypto, err := New([]string{key}, encryptionPublicKey, encryptionKey, WithSigningKey(privateKey))
+	assert.NoError(t, err)
+	record := &consentRecord{UserID: "user-1", Purpose: "marketing", Granted: true}
+	record.Signature, err = rsaCrypto.(Signer).Sign(record)
+	assert.NoError(t, err)
+	assert.Equal(t, SignatureRSAPSS, record.Signature.Algorithm)
+	assert.NoError(t, rsaCrypto.(Signer).Verify(record, record.Signature))
+	// after rotating to ed25519, records signed with the old key still verify
+	edCrypto, err := New([]string{key}, nil, nil, WithSigningKey(edPrivateKey), WithVerificationKeys(publicKey))
+	assert.NoError(t, err)
+	assert.NoError(t, edCrypto.(Signer).Verify(record, record.Signature))
+	newRecord := &consentRecord{UserID: "user-2", Purpose: "analytics"}
+	newRecord.Signature, err = edCrypto.(Signer).Sign(newRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, SignatureEd25519, newRecord.Signature.Algorithm)
+	assert.NoError(t, edCrypto.(Signer).Verify(newRecord, newRecord.Signature))
+	// the rsa crypto does not know the ed25519 key
+	assert.ErrorIs(t, rsaCrypto.(Signer).Verify(newRecord, newRecord.Signature), ErrInvalidSignature)
+	verifyOnly, err := New([]string{key}, nil, nil, WithVerificationKeys(edPublicKey))
+	assert.NoError(t, err)
+	assert.NoError(t, verifyOnly.(Signer).Verify(newRecord, newRecord.Signature))
+	_, err = verifyOnly.(Signer).Sign(newRecord)
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+	// altered records, algorithms and signatures fail
+	newRecord.Granted = true
+	assert.ErrorIs(t, edCrypto.(Signer).Verify(newRecord, newRecord.Signature), ErrInvalidSignature)
+	newRecord.Granted = false
+	changedAlgorithm := *newRecord.Signature
+	changedAlgorithm.Algorithm = SignatureRSAPSS
+	assert.ErrorIs(t, edCrypto.(Signer).Verify(newRecord, &changedAlgorithm), ErrInvalidSignature)
+	assert.ErrorIs(t, edCrypto.(Signer).Verify(newRecord, nil), ErrInvalidSignature)
+	// the copy of the signing key is wiped on close
+	signingKey := edCrypto.(*defaultCrypto).keys.signingKey.(ed25519.PrivateKey)
+	assert.NoError(t, edCrypto.Close())
+	assert.Equal(t, make([]byte, ed25519.PrivateKeySize), []byte(signingKey))
+	assert.NotEqual(t, make([]byte, ed25519.PrivateKeySize), []byte(edPrivateKey))
+	_, err = edCrypto.(Signer).Sign(newRecord)
+	assert.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,271 @@
+package cryptox
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	subjectKeySize = 32
+	// defaultSubjectKeyTimeout bounds the subject key lookups of Decrypt, see WithSubjectKeyTimeout
+	defaultSubjectKeyTimeout = 10 * time.Second
+)
+
+var (
+	// ErrSubjectErased is returned when the key of a subject has been destroyed and its data can no longer be decrypted.
+	ErrSubjectErased = errors.New("subject erased")
+	// ErrSubjectKeyNotFound is returned when no key has ever been created for a subject.
+	ErrSubjectKeyNotFound = errors.New("subject key not found")
+	// ErrSubjectKeyExists is returned by SubjectKeyStore.Create if the subject already has a key.
+	ErrSubjectKeyExists = errors.New("subject key already exists")
+)
+
+// SubjectKeyStore persists the per subject keys used for crypto-shredding. Keys are stored wrapped
+// (encrypted) by the symmetric keys of the Crypto and never in plaintext.
+type SubjectKeyStore interface {
+	// Get returns the wrapped key of a subject, ErrSubjectErased if it has been destroyed or
+	// ErrSubjectKeyNotFound if it never existed.
+	Get(ctx context.Context, subjectID string) (*Stringx, error)
+	// Create stores a new wrapped key and returns ErrSubjectKeyExists if the subject already has one
+	// or ErrSubjectErased if the subject has been erased.
+	Create(ctx context.Context, subjectID string, wrappedKey *Stringx) error
+	// Destroy permanently deletes the key of a subject and remembers that the subject has been erased.
+	Destroy(ctx context.Context, subjectID string) error
+}
+
+// SubjectCrypto adds crypto-shredding to a Crypto. Every Crypto created by New implements it,
+// eg. c.(cryptox.SubjectCrypto).ForSubject(ctx, userID), but it needs WithSubjectKeyStore.
+type SubjectCrypto interface {
+	// ForSubject returns a Crypto that encrypts every Stringx under the key of subjectID in addition to the
+	// regular layers, creating the key if the subject has none. Decrypting works with any Crypto using the same
+	// store. ctx is only used to load or create the key, the returned Crypto does not keep it and may outlive it.
+	ForSubject(ctx context.Context, subjectID string) (Crypto, error)
+	// EraseSubject destroys the key of subjectID. All values encrypted for the subject become permanently unreadable.
+	EraseSubject(ctx context.Context, subjectID string) error
+}
+
+// subject is the subject a Crypto returned by ForSubject encrypts for.
+type subject struct {
+	id  string
+	key []byte
+	// closed is guarded by the keyring of the Crypto
+	closed bool
+}
+
+// WithSubjectKeyStore enables crypto-shredding through SubjectCrypto.
+func WithSubjectKeyStore(store SubjectKeyStore) Option {
+	return func(c *defaultCrypto) {
+		c.subjectKeyStore = store
+	}
+}
+
+// WithSubjectKeyTimeout bounds the lookup of the subject keys a Decrypt needs, as Decrypt has no context.
+// It defaults to 10 seconds.
+func WithSubjectKeyTimeout(timeout time.Duration) Option {
+	return func(c *defaultCrypto) {
+		c.subjectKeyTimeout = timeout
+	}
+}
+
+// ForSubject implements SubjectCrypto.
+func (c *defaultCrypto) ForSubject(ctx context.Context, subjectID string) (Crypto, error) {
+	if subjectID == "" {
+		return nil, errors.New("subject id is empty")
+	}
+	key, err := c.subjectKey(ctx, subjectID)
+	if errors.Is(err, ErrSubjectKeyNotFound) {
+		key, err = c.createSubjectKey(ctx, subjectID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	subjectCrypto := *c
+	subjectCrypto.subject = &subject{
+		id:  subjectID,
+		key: key,
+	}
+	return &subjectCrypto, nil
+}
+
+// EraseSubject implements SubjectCrypto.
+func (c *defaultCrypto) EraseSubject(ctx context.Context, subjectID string) error {
+	if c.subjectKeyStore == nil {
+		return errors.New("no subject key store configured")
+	}
+	return c.subjectKeyStore.Destroy(ctx, subjectID)
+}
+
+func (c *defaultCrypto) createSubjectKey(ctx context.Context, subjectID string) ([]byte, error) {
+	key := make([]byte, subjectKeySize)
+	if _, err := io.ReadFull(c.random, key); err != nil {
+		return nil, err
+	}
+	wrappedKey, err := c.wrapSubjectKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.subjectKeyStore.Create(ctx, subjectID, wrappedKey); errors.Is(err, ErrSubjectKeyExists) {
+		// the key was created concurrently - use that one
+		return c.subjectKey(ctx, subjectID)
+	} else if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (c *defaultCrypto) wrapSubjectKey(key []byte) (*Stringx, error) {
+	release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if len(c.keys.symmetricKeys) == 0 {
+		return nil, errors.New("subject keys require symmetric keys")
+	}
+	wrappedKey := &Stringx{Body: hex.EncodeToString(key)}
+	if err := c.keyWrapper().Encrypt(wrappedKey); err != nil {
+		return nil, err
+	}
+	return wrappedKey, nil
+}
+
+// subjectKey loads and unwraps the key of a subject. The store is called before the keyring is acquired.
+func (c *defaultCrypto) subjectKey(ctx context.Context, subjectID string) ([]byte, error) {
+	if c.subjectKeyStore == nil {
+		return nil, errors.New("no subject key store configured")
+	}
+	wrappedKey, err := c.subjectKeyStore.Get(ctx, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.unwrapSubjectKey(wrappedKey)
+}
+
+// unwrapSubjectKey must only be called while c is acquired.
+func (c *defaultCrypto) unwrapSubjectKey(wrappedKey *Stringx) ([]byte, error) {
+	if len(c.keys.symmetricKeys) == 0 {
+		return nil, errors.New("subject keys require symmetric keys")
+	}
+	if err := c.keyWrapper().Decrypt(wrappedKey); err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(wrappedKey.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return key, nil
+}
+
+// keyWrapper returns a Crypto which only uses the symmetric keys, so subject keys can be
+// unwrapped by services which do not hold the private key. It shares the key material
+// of c and must only be used while c is acquired.
+func (c *defaultCrypto) keyWrapper() Crypto {
+	return &defaultCrypto{
+		keys: &keyring{
+			symmetricKeys: c.keys.symmetricKeys,
+			symmetricKey:  c.keys.symmetricKey,
+		},
+		keyDerivation: c.keyDerivation,
+		random:        c.random,
+	}
+}
+
+// subjectKeys resolves and caches subject keys during a single Decrypt. The wrapped keys are loaded
+// by loadSubjectKeys before the keyring is acquired, so a slow store does not hold up Close or
+// SetSymmetricEncryptionKeys, and are unwrapped by get while it is acquired.
+type subjectKeys struct {
+	c       *defaultCrypto
+	wrapped map[string]wrappedSubjectKey
+	keys    map[string][]byte
+}
+
+type wrappedSubjectKey struct {
+	key *Stringx
+	err error
+}
+
+// loadSubjectKeys loads the wrapped keys of every subject val is encrypted for.
+func (c *defaultCrypto) loadSubjectKeys(val interface{}) (*subjectKeys, error) {
+	s := &subjectKeys{
+		c:       c,
+		wrapped: map[string]wrappedSubjectKey{},
+		keys:    map[string][]byte{},
+	}
+	if c.subjectKeyStore == nil {
+		return s, nil
+	}
+	var subjectIDs []string
+	collect := func(path string, stringx *Stringx) error {
+		if stringx.SubjectID != "" && stringx.Body != "" && (c.subject == nil || c.subject.id != stringx.SubjectID) {
+			subjectIDs = append(subjectIDs, stringx.SubjectID)
+		}
+		return nil
+	}
+	if stringx, ok := val.(*Stringx); ok {
+		if stringx != nil {
+			collect("", stringx)
+		}
+	} else if err := c.walk(val, false, collect); err != nil {
+		return nil, err
+	}
+	if len(subjectIDs) == 0 {
+		return s, nil
+	}
+	// Decrypt has no context, so the lookups are bounded by the subject key timeout
+	ctx, cancel := context.WithTimeout(context.Background(), c.subjectKeyTimeout)
+	defer cancel()
+	for _, subjectID := range subjectIDs {
+		if _, ok := s.wrapped[subjectID]; ok {
+			continue
+		}
+		key, err := c.subjectKeyStore.Get(ctx, subjectID)
+		s.wrapped[subjectID] = wrappedSubjectKey{key: key, err: err}
+	}
+	return s, nil
+}
+
+func (s *subjectKeys) get(subjectID string) ([]byte, error) {
+	if s.c.subject != nil && s.c.subject.id == subjectID {
+		return s.c.subject.key, nil
+	}
+	if key, ok := s.keys[subjectID]; ok {
+		return key, nil
+	}
+	if s.c.subjectKeyStore == nil {
+		return nil, errors.New("no subject key store configured")
+	}
+	wrapped, ok := s.wrapped[subjectID]
+	if !ok {
+		// the value was changed after the keys were loaded
+		return nil, fmt.Errorf("subject key of %q not loaded", subjectID)
+	}
+	var key []byte
+	err := wrapped.err
+	if err == nil {
+		key, err = s.c.unwrapSubjectKey(wrapped.key)
+	}
+	if errors.Is(err, ErrSubjectKeyNotFound) {
+		// a value encrypted for a subject without key can only come from an erased subject
+		err = fmt.Errorf("%w: %v", ErrSubjectErased, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.keys[subjectID] = key
+	return key, nil
+}
+
+func (s *subjectKeys) wipe() {
+	for _, key := range s.keys {
+		setZero(key)
+	}
+}
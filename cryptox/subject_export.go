@@ -0,0 +1,538 @@
+package cryptox
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	exportVersion      = 1
+	exportManifestFile = "manifest.json"
+	exportCipher       = "AES-256-GCM"
+	// maxExportSize bounds the uncompressed size of the files read from a ZIP export
+	maxExportSize = 1 << 30
+)
+
+var (
+	// ErrInvalidExport is returned by OpenExport when an export is malformed or was modified.
+	ErrInvalidExport = errors.New("invalid export")
+	// ErrNotDecryptable is returned by SubjectExporter.Export when a document holds a value the Crypto of the
+	// exporter has no key for, so the value would be exported as ciphertext.
+	ErrNotDecryptable = errors.New("value cannot be decrypted")
+)
+
+// decryptChecker is implemented by every Crypto created by New.
+type decryptChecker interface {
+	checkDecryptable(val interface{}) error
+}
+
+// ExportFormat is the container of a subject export.
+type ExportFormat string
+
+const (
+	// ExportJSON writes a single JSON document holding the manifest and the documents of every collection.
+	ExportJSON ExportFormat = "json"
+	// ExportZIP writes a ZIP archive with manifest.json and one JSON file per collection.
+	ExportZIP ExportFormat = "zip"
+)
+
+// ExportCollection describes a collection holding data of subjects.
+type ExportCollection interface {
+	// Name identifies the collection in the export and must be unique.
+	Name() string
+	// Find returns pointers to the still encrypted documents of subjectID.
+	Find(ctx context.Context, subjectID string) ([]interface{}, error)
+}
+
+// ExportManifest describes a subject export and is signed with the signing key of the Crypto, see WithSigningKey.
+// The hashes of the collections are covered by the signature, so the manifest also authenticates the documents.
+type ExportManifest struct {
+	Version     int                        `json:"version"`
+	SubjectID   string                     `json:"subject_id"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	Format      ExportFormat               `json:"format"`
+	Collections []ExportManifestCollection `json:"collections"`
+	Signature   *Signature                 `json:"signature,omitempty"`
+}
+
+// ExportManifestCollection describes the documents exported from one collection.
+type ExportManifestCollection struct {
+	Name string `json:"name"`
+	// File is the name of the file in a ZIP export
+	File      string `json:"file,omitempty"`
+	Documents int    `json:"documents"`
+	// SHA256 is the hex encoded hash of the JSON array holding the documents
+	SHA256 string `json:"sha256"`
+}
+
+// SubjectExport is an export read by OpenExport.
+type SubjectExport struct {
+	Manifest *ExportManifest
+	// Collections holds the JSON array of documents of every collection by name
+	Collections map[string]json.RawMessage
+}
+
+// ExportOption configures a single export.
+type ExportOption func(config *exportConfig)
+
+type exportConfig struct {
+	format     ExportFormat
+	passphrase []byte
+	kdf        []KeyFileOption
+	now        func() time.Time
+}
+
+// WithExportFormat selects the container of the export, it defaults to ExportZIP.
+func WithExportFormat(format ExportFormat) ExportOption {
+	return func(config *exportConfig) {
+		config.format = format
+	}
+}
+
+// WithExportPassphrase encrypts the export with a key derived from passphrase, by default with the
+// key derivation of a KeyFile. The passphrase must be handed to the subject through another channel.
+func WithExportPassphrase(passphrase []byte, opts ...KeyFileOption) ExportOption {
+	return func(config *exportConfig) {
+		config.passphrase = passphrase
+		config.kdf = opts
+	}
+}
+
+// WithExportClock sets the clock used for the creation time of the manifest.
+func WithExportClock(now func() time.Time) ExportOption {
+	return func(config *exportConfig) {
+		config.now = now
+	}
+}
+
+// SubjectExporter answers subject access requests by collecting the decrypted documents of a subject
+// from every registered collection.
+type SubjectExporter struct {
+	crypto      Crypto
+	collections []ExportCollection
+}
+
+// NewSubjectExporter creates an exporter decrypting with crypto, which must be created by New. crypto also signs
+// the manifest, so it needs a signing key, and it needs the subject key store if documents are encrypted for
+// subjects. Exports fail with ErrNotDecryptable if crypto lacks a key to decrypt any value of the subject.
+func NewSubjectExporter(crypto Crypto) *SubjectExporter {
+	return &SubjectExporter{
+		crypto: crypto,
+	}
+}
+
+// Register adds collections to every export.
+func (e *SubjectExporter) Register(collections ...ExportCollection) error {
+	for _, collection := range collections {
+		name := collection.Name()
+		if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return fmt.Errorf("invalid collection name %q", name)
+		}
+		for _, registered := range e.collections {
+			if registered.Name() == name {
+				return fmt.Errorf("collection %s is already registered", name)
+			}
+		}
+		e.collections = append(e.collections, collection)
+	}
+	return nil
+}
+
+// Export writes the data of subjectID in every registered collection to w and returns the signed manifest.
+func (e *SubjectExporter) Export(ctx context.Context, subjectID string, w io.Writer, opts ...ExportOption) (*ExportManifest, error) {
+	if subjectID == "" {
+		return nil, errors.New("subject id is empty")
+	}
+	signer, ok := e.crypto.(Signer)
+	if !ok {
+		return nil, fmt.Errorf("sign manifest: %w", ErrNoSigningKey)
+	}
+	checker, ok := e.crypto.(decryptChecker)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T cannot report values it has no key for", ErrNotDecryptable, e.crypto)
+	}
+	config := &exportConfig{
+		format: ExportZIP,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.format != ExportJSON && config.format != ExportZIP {
+		return nil, fmt.Errorf("unknown export format %q", config.format)
+	}
+	manifest := &ExportManifest{
+		Version:   exportVersion,
+		SubjectID: subjectID,
+		CreatedAt: config.now().UTC(),
+		Format:    config.format,
+	}
+	collections := map[string]json.RawMessage{}
+	for _, collection := range e.collections {
+		documents, err := e.collect(ctx, checker, collection, subjectID)
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %w", collection.Name(), err)
+		}
+		data, err := json.Marshal(documents)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(data)
+		entry := ExportManifestCollection{
+			Name:      collection.Name(),
+			Documents: len(documents),
+			SHA256:    hex.EncodeToString(hash[:]),
+		}
+		if config.format == ExportZIP {
+			entry.File = path.Join("collections", collection.Name()+".json")
+		}
+		manifest.Collections = append(manifest.Collections, entry)
+		collections[collection.Name()] = data
+	}
+	signature, err := signer.Sign(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("sign manifest: %w", err)
+	}
+	manifest.Signature = signature
+	var data []byte
+	if config.format == ExportZIP {
+		data, err = writeExportZIP(manifest, collections)
+	} else {
+		// indenting would change the collections and their hashes
+		data, err = json.Marshal(&exportDocument{Manifest: manifest, Collections: collections})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if config.passphrase != nil {
+		if data, err = sealExport(config, data); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// collect finds and decrypts the documents of subjectID. Stringx values are replaced by their body.
+func (e *SubjectExporter) collect(ctx context.Context, checker decryptChecker, collection ExportCollection, subjectID string) ([]interface{}, error) {
+	values, err := collection.Find(ctx, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	documents := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		// a value which stays encrypted would be exported as subject data
+		if err := checker.checkDecryptable(value); err != nil {
+			return nil, err
+		}
+		if err := e.crypto.Decrypt(value); err != nil {
+			return nil, err
+		}
+		document, err := plainDocument(value)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+// plainDocument returns the JSON value of val with every Stringx object replaced by its body.
+func plainDocument(val interface{}) (interface{}, error) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return replaceStringx(document), nil
+}
+
+func replaceStringx(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if body, ok := stringxBody(v); ok {
+			return body
+		}
+		for key, elem := range v {
+			v[key] = replaceStringx(elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = replaceStringx(elem)
+		}
+	}
+	return value
+}
+
+// stringxJSONNames holds the JSON names of the fields of Stringx.
+var stringxJSONNames = func() map[string]bool {
+	names := map[string]bool{}
+	t := reflect.TypeOf(Stringx{})
+	for i := 0; i < t.NumField(); i++ {
+		names[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = true
+	}
+	return names
+}()
+
+// stringxBody returns the body of an object which has the shape of a marshalled Stringx.
+func stringxBody(object map[string]interface{}) (string, bool) {
+	body, ok := object["body"].(string)
+	if _, hasLevel := object["encryption_level"]; !ok || !hasLevel {
+		return "", false
+	}
+	for key := range object {
+		if !stringxJSONNames[key] {
+			return "", false
+		}
+	}
+	return body, true
+}
+
+// exportDocument is an ExportJSON export.
+type exportDocument struct {
+	Manifest    *ExportManifest            `json:"manifest"`
+	Collections map[string]json.RawMessage `json:"collections"`
+}
+
+func writeExportZIP(manifest *ExportManifest, collections map[string]json.RawMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{{exportManifestFile, manifestData}}
+	for _, collection := range manifest.Collections {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{collection.File, collections[collection.Name]})
+	}
+	for _, file := range files {
+		writer, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: manifest.CreatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writer.Write(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encryptedExport is a passphrase encrypted export. The header is authenticated together with the export.
+type encryptedExport struct {
+	Version    int              `json:"version"`
+	KDF        KeyFileKDFParams `json:"kdf"`
+	Cipher     string           `json:"cipher"`
+	Format     ExportFormat     `json:"format"`
+	Nonce      []byte           `json:"nonce"`
+	Ciphertext []byte           `json:"ciphertext"`
+}
+
+func (e *encryptedExport) header() ([]byte, error) {
+	return json.Marshal(struct {
+		Version int              `json:"version"`
+		KDF     KeyFileKDFParams `json:"kdf"`
+		Cipher  string           `json:"cipher"`
+		Format  ExportFormat     `json:"format"`
+	}{e.Version, e.KDF, e.Cipher, e.Format})
+}
+
+func sealExport(config *exportConfig, data []byte) ([]byte, error) {
+	sealed := &encryptedExport{
+		Version: exportVersion,
+		KDF:     KeyFileKDFParams{Name: KDFScrypt, N: 1 << 15, R: 8, P: 1},
+		Cipher:  exportCipher,
+		Format:  config.format,
+	}
+	for _, opt := range config.kdf {
+		opt(&sealed.KDF)
+	}
+	sealed.KDF.Salt = make([]byte, keyFileSaltSize)
+	if _, err := io.ReadFull(rand.Reader, sealed.KDF.Salt); err != nil {
+		return nil, err
+	}
+	aesGCM, err := sealed.KDF.cipher(config.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	sealed.Nonce = make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, sealed.Nonce); err != nil {
+		return nil, err
+	}
+	aad, err := sealed.header()
+	if err != nil {
+		return nil, err
+	}
+	sealed.Ciphertext = aesGCM.Seal(nil, sealed.Nonce, data, aad)
+	return json.MarshalIndent(sealed, "", "  ")
+}
+
+func openExport(data, passphrase []byte) ([]byte, ExportFormat, error) {
+	sealed := &encryptedExport{}
+	if err := json.Unmarshal(data, sealed); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	if sealed.Version != exportVersion || sealed.Cipher != exportCipher {
+		return nil, "", fmt.Errorf("%w: unsupported version or cipher", ErrInvalidExport)
+	}
+	aesGCM, err := sealed.KDF.cipher(passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	aad, err := sealed.header()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(sealed.Nonce) != aesGCM.NonceSize() {
+		return nil, "", fmt.Errorf("%w: invalid nonce", ErrInvalidExport)
+	}
+	plaintext, err := aesGCM.Open(nil, sealed.Nonce, sealed.Ciphertext, aad)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: wrong passphrase or corrupted export", ErrAuthentication)
+	}
+	return plaintext, sealed.Format, nil
+}
+
+// OpenExport reads an export written by SubjectExporter.Export and checks the hashes of the collections.
+// passphrase is only needed if the export is encrypted. Use SubjectExport.Verify to check the signature.
+func OpenExport(data, passphrase []byte) (*SubjectExport, error) {
+	format := ExportJSON
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		format = ExportZIP
+	}
+	if passphrase != nil {
+		var err error
+		if data, format, err = openExport(data, passphrase); err != nil {
+			return nil, err
+		}
+	}
+	export := &SubjectExport{}
+	var err error
+	switch format {
+	case ExportZIP:
+		err = export.readZIP(data)
+	case ExportJSON:
+		document := &exportDocument{}
+		if err = json.Unmarshal(data, document); err == nil && document.Manifest == nil {
+			err = errors.New("no manifest, the export may be encrypted")
+		}
+		export.Manifest, export.Collections = document.Manifest, document.Collections
+	default:
+		err = fmt.Errorf("unknown export format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	if export.Manifest.Format != format {
+		return nil, fmt.Errorf("%w: manifest format %q does not match %q", ErrInvalidExport, export.Manifest.Format, format)
+	}
+	if len(export.Collections) != len(export.Manifest.Collections) {
+		return nil, fmt.Errorf("%w: collections do not match the manifest", ErrInvalidExport)
+	}
+	for _, collection := range export.Manifest.Collections {
+		data, ok := export.Collections[collection.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: collection %s is missing", ErrInvalidExport, collection.Name)
+		}
+		hash := sha256.Sum256(data)
+		if hex.EncodeToString(hash[:]) != collection.SHA256 {
+			return nil, fmt.Errorf("%w: hash of collection %s does not match", ErrInvalidExport, collection.Name)
+		}
+	}
+	return export, nil
+}
+
+func (export *SubjectExport) readZIP(data []byte) error {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	// the files of an export share one size limit, so many small files cannot add up to a large one
+	remaining := int64(maxExportSize)
+	manifestData, err := readZIPFile(files[exportManifestFile], remaining)
+	if err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	remaining -= int64(len(manifestData))
+	export.Manifest = &ExportManifest{}
+	if err := json.Unmarshal(manifestData, export.Manifest); err != nil {
+		return err
+	}
+	export.Collections = map[string]json.RawMessage{}
+	for _, collection := range export.Manifest.Collections {
+		data, err := readZIPFile(files[collection.File], remaining)
+		if err != nil {
+			return fmt.Errorf("collection %s: %w", collection.Name, err)
+		}
+		remaining -= int64(len(data))
+		export.Collections[collection.Name] = data
+	}
+	return nil
+}
+
+// readZIPFile reads file and fails if it is larger than limit bytes. The size in the header is checked first,
+// but as it can be forged the data is read through a limited reader as well.
+func readZIPFile(file *zip.File, limit int64) ([]byte, error) {
+	if file == nil {
+		return nil, errors.New("file not found")
+	}
+	if file.UncompressedSize64 > uint64(limit) {
+		return nil, fmt.Errorf("file %s is larger than %d bytes", file.Name, limit)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file %s is larger than %d bytes", file.Name, limit)
+	}
+	return data, nil
+}
+
+// Verify checks the signature of the manifest with the verification keys of signer.
+func (export *SubjectExport) Verify(signer Signer) error {
+	if export.Manifest.Signature == nil {
+		return ErrInvalidSignature
+	}
+	return signer.Verify(export.Manifest, export.Manifest.Signature)
+}
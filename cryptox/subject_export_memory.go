@@ -0,0 +1,55 @@
+package cryptox
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// MemoryExportCollection is an ExportCollection which keeps JSON encoded documents in memory. Useful for tests.
+type MemoryExportCollection struct {
+	mu       sync.RWMutex
+	name     string
+	docs     map[string][][]byte
+	newValue func() interface{}
+}
+
+// NewMemoryExportCollection returns an empty collection. newValue returns a pointer to a new document
+// which found documents are decoded into.
+func NewMemoryExportCollection(name string, newValue func() interface{}) *MemoryExportCollection {
+	return &MemoryExportCollection{
+		name:     name,
+		docs:     map[string][][]byte{},
+		newValue: newValue,
+	}
+}
+
+// Add stores value as a document of subjectID.
+func (c *MemoryExportCollection) Add(subjectID string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs[subjectID] = append(c.docs[subjectID], data)
+	return nil
+}
+
+func (c *MemoryExportCollection) Name() string {
+	return c.name
+}
+
+func (c *MemoryExportCollection) Find(ctx context.Context, subjectID string) ([]interface{}, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	values := make([]interface{}, 0, len(c.docs[subjectID]))
+	for _, data := range c.docs[subjectID] {
+		value := c.newValue()
+		if err := json.Unmarshal(data, value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
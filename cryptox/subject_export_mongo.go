@@ -0,0 +1,49 @@
+package cryptox
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoExportCollection struct {
+	name         string
+	collection   *mongo.Collection
+	subjectField string
+	newValue     func() interface{}
+}
+
+// NewMongoExportCollection describes a Mongo collection whose documents belong to the subject stored in subjectField.
+// newValue returns a pointer to a new document which found documents are decoded into. Documents are decoded
+// with the default registry, so a client registry from NewBSONRegistry is bypassed.
+func NewMongoExportCollection(name string, collection *mongo.Collection, subjectField string, newValue func() interface{}) ExportCollection {
+	return &mongoExportCollection{
+		name:         name,
+		collection:   collection,
+		subjectField: subjectField,
+		newValue:     newValue,
+	}
+}
+
+func (c *mongoExportCollection) Name() string {
+	return c.name
+}
+
+func (c *mongoExportCollection) Find(ctx context.Context, subjectID string) ([]interface{}, error) {
+	cursor, err := c.collection.Find(ctx, bson.M{c.subjectField: subjectID}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var values []interface{}
+	for cursor.Next(ctx) {
+		value := c.newValue()
+		if err := bson.Unmarshal(cursor.Current, value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, cursor.Err()
+}
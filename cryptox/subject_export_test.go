@@ -0,0 +1,174 @@
+package cryptox
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type profile struct {
+	UserID string  `json:"user_id"`
+	Name   Stringx `json:"name"`
+	Email  Stringx `json:"email"`
+}
+
+type message struct {
+	UserID string    `json:"user_id"`
+	Text   Stringx   `json:"text"`
+	Tags   []Stringx `json:"tags"`
+}
+
+func TestSubjectExport(t *testing.T) {
+	ctx := context.Background()
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	_, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	crypto, err := New([]string{key}, nil, nil, WithSigningKey(signingKey), WithSubjectKeyStore(NewMemorySubjectKeyStore()))
+	assert.NoError(t, err)
+	subjectCrypto, err := crypto.(SubjectCrypto).ForSubject(ctx, "user-1")
+	assert.NoError(t, err)
+	profiles := NewMemoryExportCollection("profiles", func() interface{} { return &profile{} })
+	messages := NewMemoryExportCollection("messages", func() interface{} { return &message{} })
+	for _, value := range []*profile{
+		{UserID: "user-1", Name: Stringx{Body: "Jane Doe"}, Email: Stringx{Body: "jane@example.org"}},
+		{UserID: "user-2", Name: Stringx{Body: "John Doe"}},
+	} {
+		assert.NoError(t, crypto.Encrypt(value))
+		assert.NoError(t, profiles.Add(value.UserID, value))
+	}
+	value := &message{UserID: "user-1", Text: Stringx{Body: "hello <world>"}, Tags: []Stringx{{Body: "private"}}}
+	assert.NoError(t, subjectCrypto.Encrypt(value))
+	assert.NoError(t, messages.Add("user-1", value))
+	exporter := NewSubjectExporter(crypto)
+	assert.NoError(t, exporter.Register(profiles, messages))
+	assert.Error(t, exporter.Register(NewMemoryExportCollection("profiles", nil)))
+	assert.Error(t, exporter.Register(NewMemoryExportCollection("../profiles", nil)))
+	createdAt := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := WithExportClock(func() time.Time { return createdAt })
+	for _, format := range []ExportFormat{ExportZIP, ExportJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			manifest, err := exporter.Export(ctx, "user-1", &buf, WithExportFormat(format), clock)
+			assert.NoError(t, err)
+			assert.Equal(t, createdAt, manifest.CreatedAt)
+			assert.Len(t, manifest.Collections, 2)
+			assert.Equal(t, 1, manifest.Collections[0].Documents)
+			assert.NotContains(t, buf.String(), "John Doe")
+			export, err := OpenExport(buf.Bytes(), nil)
+			assert.NoError(t, err)
+			assert.NoError(t, export.Verify(crypto.(Signer)))
+			var exportedProfiles []map[string]interface{}
+			assert.NoError(t, json.Unmarshal(export.Collections["profiles"], &exportedProfiles))
+			assert.Equal(t, []map[string]interface{}{{"user_id": "user-1", "name": "Jane Doe", "email": "jane@example.org"}}, exportedProfiles)
+			var exportedMessages []map[string]interface{}
+			assert.NoError(t, json.Unmarshal(export.Collections["messages"], &exportedMessages))
+			assert.Equal(t, "hello <world>", exportedMessages[0]["text"])
+			assert.Equal(t, []interface{}{"private"}, exportedMessages[0]["tags"])
+			// a modified document no longer matches the manifest
+			tampered := bytes.Replace(buf.Bytes(), []byte("user-1"), []byte("user-3"), -1)
+			if format == ExportJSON {
+				_, err = OpenExport(tampered, nil)
+				assert.ErrorIs(t, err, ErrInvalidExport)
+			}
+			// a modified manifest no longer matches the signature
+			export.Manifest.SubjectID = "user-2"
+			assert.ErrorIs(t, export.Verify(crypto.(Signer)), ErrInvalidSignature)
+		})
+	}
+	// passphrase encrypted exports
+	var buf bytes.Buffer
+	passphrase := []byte("correct horse battery staple")
+	_, err = exporter.Export(ctx, "user-1", &buf, WithExportPassphrase(passphrase, WithScrypt(1<<14, 8, 1)))
+	assert.NoError(t, err)
+	assert.NotContains(t, buf.String(), "Jane Doe")
+	_, err = OpenExport(buf.Bytes(), []byte("wrong"))
+	assert.ErrorIs(t, err, ErrAuthentication)
+	_, err = OpenExport(buf.Bytes(), nil)
+	assert.ErrorIs(t, err, ErrInvalidExport)
+	export, err := OpenExport(buf.Bytes(), passphrase)
+	assert.NoError(t, err)
+	assert.Equal(t, ExportZIP, export.Manifest.Format)
+	// the key derivation of an export is bounded before the passphrase is used
+	sealed := &encryptedExport{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), sealed))
+	sealed.KDF.N = 1 << 30
+	costly, err := json.Marshal(sealed)
+	assert.NoError(t, err)
+	_, err = OpenExport(costly, passphrase)
+	assert.EqualError(t, err, "invalid scrypt parameters")
+	assert.NoError(t, export.Verify(crypto.(Signer)))
+	// without a signing key there is no export
+	unsigned, err := New([]string{key}, nil, nil)
+	assert.NoError(t, err)
+	unsignedExporter := NewSubjectExporter(unsigned)
+	assert.NoError(t, unsignedExporter.Register(profiles))
+	_, err = unsignedExporter.Export(ctx, "user-1", &buf)
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+	// neither with a Crypto which cannot sign at all
+	decryptOnlyExporter := NewSubjectExporter(struct{ Crypto }{crypto})
+	assert.NoError(t, decryptOnlyExporter.Register(profiles))
+	_, err = decryptOnlyExporter.Export(ctx, "user-1", &buf)
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+	// values the Crypto of the exporter has no key for are not exported as ciphertext
+	_, x25519PublicKey, err := GenerateX25519KeyPair()
+	assert.NoError(t, err)
+	sealedCrypto, err := New([]string{key}, nil, nil, WithX25519Keys(x25519PublicKey, nil))
+	assert.NoError(t, err)
+	notes := NewMemoryExportCollection("notes", func() interface{} { return &message{} })
+	note := &message{UserID: "user-1", Text: Stringx{Body: "sealed"}}
+	assert.NoError(t, sealedCrypto.Encrypt(note))
+	assert.NoError(t, notes.Add("user-1", note))
+	assert.NoError(t, exporter.Register(notes))
+	buf.Reset()
+	_, err = exporter.Export(ctx, "user-1", &buf)
+	assert.ErrorIs(t, err, ErrNotDecryptable)
+	assert.Contains(t, err.Error(), "collection notes: Text")
+	assert.Zero(t, buf.Len())
+}
+
+func TestReadZIPFile(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, err := archive.Create("small.json")
+	assert.NoError(t, err)
+	_, err = w.Write([]byte(`[{"a":1}]`))
+	assert.NoError(t, err)
+	w, err = archive.Create("large.json")
+	assert.NoError(t, err)
+	_, err = w.Write(bytes.Repeat([]byte(" "), 1024))
+	assert.NoError(t, err)
+	// the header of forged.json claims 8 bytes but the data inflates to 1 KiB
+	var deflated bytes.Buffer
+	compressor, err := flate.NewWriter(&deflated, flate.BestCompression)
+	assert.NoError(t, err)
+	_, err = compressor.Write(bytes.Repeat([]byte(" "), 1024))
+	assert.NoError(t, err)
+	assert.NoError(t, compressor.Close())
+	w, err = archive.CreateRaw(&zip.FileHeader{Name: "forged.json", Method: zip.Deflate, UncompressedSize64: 8, CompressedSize64: uint64(deflated.Len())})
+	assert.NoError(t, err)
+	_, err = w.Write(deflated.Bytes())
+	assert.NoError(t, err)
+	assert.NoError(t, archive.Close())
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	data, err := readZIPFile(reader.File[0], 64)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"a":1}]`, string(data))
+	_, err = readZIPFile(reader.File[0], 4)
+	assert.Error(t, err)
+	_, err = readZIPFile(reader.File[1], 64)
+	assert.Error(t, err)
+	_, err = readZIPFile(reader.File[2], 64)
+	assert.Error(t, err)
+	_, err = readZIPFile(nil, 64)
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package cryptox
+
+import (
+	"context"
+	"sync"
+)
+
+type memorySubjectKeyStore struct {
+	mu     sync.RWMutex
+	keys   map[string]Stringx
+	erased map[string]bool
+}
+
+// NewMemorySubjectKeyStore returns a SubjectKeyStore which keeps keys in memory. Useful for tests and single instance tools.
+func NewMemorySubjectKeyStore() SubjectKeyStore {
+	return &memorySubjectKeyStore{
+		keys:   map[string]Stringx{},
+		erased: map[string]bool{},
+	}
+}
+
+func (s *memorySubjectKeyStore) Get(ctx context.Context, subjectID string) (*Stringx, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.erased[subjectID] {
+		return nil, ErrSubjectErased
+	}
+	key, ok := s.keys[subjectID]
+	if !ok {
+		return nil, ErrSubjectKeyNotFound
+	}
+	return &key, nil
+}
+
+func (s *memorySubjectKeyStore) Create(ctx context.Context, subjectID string, wrappedKey *Stringx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.erased[subjectID] {
+		return ErrSubjectErased
+	}
+	if _, ok := s.keys[subjectID]; ok {
+		return ErrSubjectKeyExists
+	}
+	s.keys[subjectID] = *wrappedKey
+	return nil
+}
+
+func (s *memorySubjectKeyStore) Destroy(ctx context.Context, subjectID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, subjectID)
+	s.erased[subjectID] = true
+	return nil
+}
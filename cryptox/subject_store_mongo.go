@@ -0,0 +1,79 @@
+package cryptox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type subjectKeyDocument struct {
+	SubjectID string     `bson:"_id"`
+	Key       *Stringx   `bson:"key,omitempty"`
+	CreatedAt time.Time  `bson:"created_at"`
+	ErasedAt  *time.Time `bson:"erased_at,omitempty"`
+}
+
+type mongoSubjectKeyStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoSubjectKeyStore returns a SubjectKeyStore backed by a collection with one document per subject.
+// Erased subjects keep a document without key so they are reported as erased rather than unknown. The wrapped
+// keys are already encrypted, so documents are encoded with the default registry and a client registry from
+// NewBSONRegistry is bypassed.
+func NewMongoSubjectKeyStore(collection *mongo.Collection) SubjectKeyStore {
+	return &mongoSubjectKeyStore{
+		collection: collection,
+	}
+}
+
+func (s *mongoSubjectKeyStore) Get(ctx context.Context, subjectID string) (*Stringx, error) {
+	raw, err := s.collection.FindOne(ctx, bson.M{"_id": subjectID}).DecodeBytes()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSubjectKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	doc := &subjectKeyDocument{}
+	if err := bson.Unmarshal(raw, doc); err != nil {
+		return nil, err
+	}
+	if doc.ErasedAt != nil || doc.Key == nil {
+		return nil, ErrSubjectErased
+	}
+	return doc.Key, nil
+}
+
+func (s *mongoSubjectKeyStore) Create(ctx context.Context, subjectID string, wrappedKey *Stringx) error {
+	raw, err := bson.Marshal(&subjectKeyDocument{
+		SubjectID: subjectID,
+		Key:       wrappedKey,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.collection.InsertOne(ctx, bson.Raw(raw))
+	if mongo.IsDuplicateKeyError(err) {
+		if _, err := s.Get(ctx, subjectID); errors.Is(err, ErrSubjectErased) {
+			return ErrSubjectErased
+		}
+		return ErrSubjectKeyExists
+	}
+	return err
+}
+
+func (s *mongoSubjectKeyStore) Destroy(ctx context.Context, subjectID string) error {
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": subjectID}, bson.M{
+		"$unset": bson.M{"key": ""},
+		"$set":   bson.M{"erased_at": time.Now()},
+		"$setOnInsert": bson.M{
+			"created_at": time.Now(),
+		},
+	}, options.Update().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,143 @@
+package cryptox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubjectErasure(t *testing.T) {
+	ctx := context.Background()
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	store := NewMemorySubjectKeyStore()
+	crypto, err := New([]string{key}, nil, nil, WithSubjectKeyStore(store))
+	assert.NoError(t, err)
+	c := crypto.(SubjectCrypto)
+	alice, err := c.ForSubject(ctx, "alice")
+	assert.NoError(t, err)
+	bob, err := c.ForSubject(ctx, "bob")
+	assert.NoError(t, err)
+	aliceData := &InnerStruct{One: Stringx{Body: "alice"}}
+	bobData := &InnerStruct{One: Stringx{Body: "bob"}}
+	assert.NoError(t, alice.Encrypt(aliceData))
+	assert.NoError(t, bob.Encrypt(bobData))
+	assert.Equal(t, "alice", aliceData.One.SubjectID)
+	assert.Equal(t, int32(1), aliceData.One.EncryptionLevel)
+	// the subject key is reused and values can be decrypted by any crypto sharing the store
+	aliceAgain, err := c.ForSubject(ctx, "alice")
+	assert.NoError(t, err)
+	copied := *aliceData
+	assert.NoError(t, aliceAgain.Decrypt(&copied))
+	assert.Equal(t, "alice", copied.One.Body)
+	other, err := New([]string{key}, nil, nil, WithSubjectKeyStore(store))
+	assert.NoError(t, err)
+	copied = *aliceData
+	assert.NoError(t, other.Decrypt(&copied))
+	assert.Equal(t, "alice", copied.One.Body)
+	assert.Equal(t, "", copied.One.SubjectID)
+	// erasing alice leaves bob readable
+	assert.NoError(t, c.EraseSubject(ctx, "alice"))
+	assert.ErrorIs(t, crypto.Decrypt(aliceData), ErrSubjectErased)
+	assert.NoError(t, crypto.Decrypt(bobData))
+	assert.Equal(t, "bob", bobData.One.Body)
+	_, err = c.ForSubject(ctx, "alice")
+	assert.ErrorIs(t, err, ErrSubjectErased)
+	// a value for a subject the store has never seen is treated as erased
+	unknown, err := New([]string{key}, nil, nil, WithSubjectKeyStore(NewMemorySubjectKeyStore()))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, unknown.Decrypt(aliceData), ErrSubjectErased)
+}
+
+// contextSubjectKeyStore fails like a database client once the context of a call is done.
+type contextSubjectKeyStore struct {
+	SubjectKeyStore
+}
+
+func (s *contextSubjectKeyStore) Get(ctx context.Context, subjectID string) (*Stringx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.SubjectKeyStore.Get(ctx, subjectID)
+}
+
+func TestForSubjectOutlivesContext(t *testing.T) {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	crypto, err := New([]string{key}, nil, nil, WithSubjectKeyStore(&contextSubjectKeyStore{NewMemorySubjectKeyStore()}))
+	assert.NoError(t, err)
+	c := crypto.(SubjectCrypto)
+	bob, err := c.ForSubject(context.Background(), "bob")
+	assert.NoError(t, err)
+	bobData := &InnerStruct{One: Stringx{Body: "bob"}}
+	assert.NoError(t, bob.Encrypt(bobData))
+	ctx, cancel := context.WithCancel(context.Background())
+	alice, err := c.ForSubject(ctx, "alice")
+	assert.NoError(t, err)
+	cancel()
+	// the Crypto of alice keeps working after the request which created it has ended
+	aliceData := &InnerStruct{One: Stringx{Body: "alice"}}
+	assert.NoError(t, alice.Encrypt(aliceData))
+	assert.NoError(t, alice.Decrypt(aliceData))
+	assert.Equal(t, "alice", aliceData.One.Body)
+	assert.NoError(t, alice.Decrypt(bobData))
+	assert.Equal(t, "bob", bobData.One.Body)
+}
+
+// hangingSubjectKeyStore blocks every Get until the context of the call is done or the store is released.
+type hangingSubjectKeyStore struct {
+	SubjectKeyStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func newHangingSubjectKeyStore(store SubjectKeyStore) *hangingSubjectKeyStore {
+	return &hangingSubjectKeyStore{
+		SubjectKeyStore: store,
+		started:         make(chan struct{}),
+		release:         make(chan struct{}),
+	}
+}
+
+func (s *hangingSubjectKeyStore) Get(ctx context.Context, subjectID string) (*Stringx, error) {
+	close(s.started)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.release:
+		return s.SubjectKeyStore.Get(ctx, subjectID)
+	}
+}
+
+func TestDecryptSubjectKeyLookup(t *testing.T) {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	store := NewMemorySubjectKeyStore()
+	crypto, err := New([]string{key}, nil, nil, WithSubjectKeyStore(store))
+	assert.NoError(t, err)
+	alice, err := crypto.(SubjectCrypto).ForSubject(context.Background(), "alice")
+	assert.NoError(t, err)
+	aliceData := &InnerStruct{One: Stringx{Body: "alice"}}
+	assert.NoError(t, alice.Encrypt(aliceData))
+	// a Decrypt waiting for the store fails once the timeout is reached
+	hanging := newHangingSubjectKeyStore(store)
+	timed, err := New([]string{key}, nil, nil, WithSubjectKeyStore(hanging), WithSubjectKeyTimeout(10*time.Millisecond))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, timed.Decrypt(aliceData), context.DeadlineExceeded)
+	assert.Equal(t, "alice", aliceData.One.SubjectID)
+	// the lookup does not hold the keyring, so Close does not wait for the store
+	hanging = newHangingSubjectKeyStore(store)
+	other, err := New([]string{key}, nil, nil, WithSubjectKeyStore(hanging), WithSubjectKeyTimeout(time.Hour))
+	assert.NoError(t, err)
+	decrypted := make(chan error)
+	go func() {
+		copied := *aliceData
+		decrypted <- other.Decrypt(&copied)
+	}()
+	<-hanging.started
+	assert.NoError(t, other.Close())
+	close(hanging.release)
+	assert.ErrorIs(t, <-decrypted, ErrClosed)
+}
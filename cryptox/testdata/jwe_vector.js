@@ -0,0 +1,21 @@
+// Prints a level key and a nested JWE for TestJWEVector, run in the testdata directory with Node.js 20:
+// a dir/A256GCM JWE under the level key holding a RSA-OAEP-256/A256GCM JWE for jwe_rsa_key.pem.
+const crypto = require('crypto');
+const fs = require('fs');
+const b64 = (b) => Buffer.from(b).toString('base64url');
+function seal(header, cek, encryptedKey, plaintext) {
+  const protectedHeader = b64(JSON.stringify(header));
+  const iv = crypto.randomBytes(12);
+  const cipher = crypto.createCipheriv('aes-256-gcm', cek, iv);
+  cipher.setAAD(Buffer.from(protectedHeader, 'ascii'));
+  const ciphertext = Buffer.concat([cipher.update(plaintext), cipher.final()]);
+  return [protectedHeader, b64(encryptedKey), b64(iv), b64(ciphertext), b64(cipher.getAuthTag())].join('.');
+}
+const pem = fs.readFileSync('jwe_rsa_key.pem');
+const cek = crypto.randomBytes(32);
+const encryptedKey = crypto.publicEncrypt({key: crypto.createPublicKey(pem), padding: crypto.constants.RSA_PKCS1_OAEP_PADDING, oaepHash: 'sha256'}, cek);
+const inner = seal({alg: 'RSA-OAEP-256', enc: 'A256GCM'}, cek, encryptedKey, Buffer.from('4111 1111 1111 1111'));
+const levelKey = crypto.randomBytes(32);
+const outer = seal({alg: 'dir', enc: 'A256GCM', cty: 'JWE'}, levelKey, Buffer.alloc(0), Buffer.from(inner));
+console.log(levelKey.toString('hex'));
+console.log(outer);
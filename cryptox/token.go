@@ -0,0 +1,355 @@
+package cryptox
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	tokenHeader    = "v4.local."
+	tokenNonceSize = 32
+	tokenTagSize   = 32
+	tokenKeySalt   = "cryptox token key"
+)
+
+var (
+	// ErrInvalidToken is returned when a token is malformed, fails authentication or does not match the expected claims.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned when a valid token has expired.
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// TokenClaims are the encrypted claims of a token.
+type TokenClaims struct {
+	Issuer   string `json:"iss,omitempty"`
+	Subject  string `json:"sub,omitempty"`
+	Audience string `json:"aud,omitempty"`
+	// Type is the kind of token, for example TokenTypeAccess or TokenTypeRefresh
+	Type      string            `json:"typ,omitempty"`
+	ID        string            `json:"jti,omitempty"`
+	IssuedAt  time.Time         `json:"iat"`
+	ExpiresAt time.Time         `json:"exp"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+type tokenOptions struct {
+	footer            map[string]string
+	implicitAssertion []byte
+	audience          string
+	tokenType         string
+	now               func() time.Time
+}
+
+// TokenIssuer issues and parses tokens with the symmetric keys of a Crypto. Every Crypto created by New
+// implements it, eg. c.(cryptox.TokenIssuer).IssueToken(claims).
+type TokenIssuer interface {
+	// IssueToken returns an encrypted and authenticated PASETO v4.local token holding claims. The token key is
+	// derived from the symmetric key of the highest level and ExpiresAt is required.
+	IssueToken(claims *TokenClaims, opts ...TokenOption) (string, error)
+	// ParseToken decrypts a token issued by IssueToken with any level of the current keys and checks its expiry,
+	// audience and type.
+	ParseToken(token string, opts ...TokenOption) (*TokenClaims, error)
+}
+
+// TokenOption configures IssueToken and ParseToken.
+type TokenOption func(o *tokenOptions)
+
+// WithTokenFooter adds values to the footer of an issued token. The footer is authenticated but not encrypted,
+// the key ID is always added as "kid".
+func WithTokenFooter(footer map[string]string) TokenOption {
+	return func(o *tokenOptions) {
+		o.footer = footer
+	}
+}
+
+// WithImplicitAssertion binds a token to data which is not part of the token, for example the id of the
+// row it is stored in. The same assertion must be given to issue and parse the token.
+func WithImplicitAssertion(assertion []byte) TokenOption {
+	return func(o *tokenOptions) {
+		o.implicitAssertion = assertion
+	}
+}
+
+// WithAudience makes ParseToken reject tokens issued for another audience.
+func WithAudience(audience string) TokenOption {
+	return func(o *tokenOptions) {
+		o.audience = audience
+	}
+}
+
+// WithTokenType makes ParseToken reject tokens of another type.
+func WithTokenType(tokenType string) TokenOption {
+	return func(o *tokenOptions) {
+		o.tokenType = tokenType
+	}
+}
+
+// WithTokenClock sets the clock used to check expiry, it defaults to time.Now.
+func WithTokenClock(now func() time.Time) TokenOption {
+	return func(o *tokenOptions) {
+		o.now = now
+	}
+}
+
+func newTokenOptions(opts []TokenOption) *tokenOptions {
+	o := &tokenOptions{now: time.Now}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// IssueToken implements TokenIssuer.
+func (c *defaultCrypto) IssueToken(claims *TokenClaims, opts ...TokenOption) (string, error) {
+	if claims == nil {
+		return "", errors.New("invalid claims - claims are nil")
+	}
+	if claims.ExpiresAt.IsZero() {
+		return "", errors.New("invalid claims - expires at is required")
+	}
+	o := newTokenOptions(opts)
+	release, err := c.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	key, err := tokenKey(c.keys.symmetricKeys, len(c.keys.symmetricKeys))
+	if err != nil {
+		return "", err
+	}
+	defer setZero(key)
+	footer := map[string]string{}
+	for k, v := range o.footer {
+		footer[k] = v
+	}
+	footer["kid"] = tokenKeyID(key)
+	encodedFooter, err := json.Marshal(footer)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return sealToken(c.random, key, payload, encodedFooter, o.implicitAssertion)
+}
+
+// ParseToken implements TokenIssuer.
+func (c *defaultCrypto) ParseToken(token string, opts ...TokenOption) (*TokenClaims, error) {
+	o := newTokenOptions(opts)
+	footer, err := TokenFooter(token)
+	if err != nil {
+		return nil, err
+	}
+	release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var key []byte
+	for level := len(c.keys.symmetricKeys); level > 0 && key == nil; level-- {
+		levelKey, err := tokenKey(c.keys.symmetricKeys, level)
+		if err != nil {
+			return nil, err
+		}
+		if subtle.ConstantTimeCompare([]byte(tokenKeyID(levelKey)), []byte(footer["kid"])) == 1 {
+			key = levelKey
+		} else {
+			setZero(levelKey)
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidToken, footer["kid"])
+	}
+	defer setZero(key)
+	payload, err := openToken(key, token, o.implicitAssertion)
+	if err != nil {
+		return nil, err
+	}
+	claims := &TokenClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	if !o.now().Before(claims.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	if o.audience != "" && claims.Audience != o.audience {
+		return nil, fmt.Errorf("%w: audience %q is not %q", ErrInvalidToken, claims.Audience, o.audience)
+	}
+	if o.tokenType != "" && claims.Type != o.tokenType {
+		return nil, fmt.Errorf("%w: type %q is not %q", ErrInvalidToken, claims.Type, o.tokenType)
+	}
+	return claims, nil
+}
+
+// TokenFooter returns the footer of a token without verifying it. Only use it to pick the key of a token,
+// the footer can be trusted once ParseToken succeeds.
+func TokenFooter(token string) (map[string]string, error) {
+	footer := map[string]string{}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 && len(parts) != 4 || parts[0]+"."+parts[1]+"." != tokenHeader {
+		return nil, fmt.Errorf("%w: not a v4.local token", ErrInvalidToken)
+	}
+	if len(parts) == 3 {
+		return footer, nil
+	}
+	encodedFooter, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	if err := json.Unmarshal(encodedFooter, &footer); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	return footer, nil
+}
+
+// tokenKey derives the token key of level, so tokens never share a key with encrypted values.
+func tokenKey(symmetricKeys [][]byte, level int) ([]byte, error) {
+	levelKey, err := hkdfLevelKey(symmetricKeys, level)
+	if err != nil {
+		return nil, err
+	}
+	defer setZero(levelKey)
+	key := make([]byte, chacha20.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, levelKey, []byte(tokenKeySalt), []byte(tokenHeader)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func tokenKeyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte(tokenKeySalt+" id "), key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// sealToken encrypts payload as described by PASETO v4.local.
+func sealToken(random io.Reader, key, payload, footer, implicitAssertion []byte) (string, error) {
+	nonce := make([]byte, tokenNonceSize)
+	if _, err := io.ReadFull(random, nonce); err != nil {
+		return "", err
+	}
+	encryptionKey, counterNonce, authKey, err := splitTokenKey(key, nonce)
+	if err != nil {
+		return "", err
+	}
+	defer setZero(encryptionKey)
+	defer setZero(authKey)
+	ciphertext := make([]byte, len(payload))
+	stream, err := chacha20.NewUnauthenticatedCipher(encryptionKey, counterNonce)
+	if err != nil {
+		return "", err
+	}
+	stream.XORKeyStream(ciphertext, payload)
+	tag, err := tokenTag(authKey, nonce, ciphertext, footer, implicitAssertion)
+	if err != nil {
+		return "", err
+	}
+	body := append(append(nonce, ciphertext...), tag...)
+	token := tokenHeader + base64.RawURLEncoding.EncodeToString(body)
+	if len(footer) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+	return token, nil
+}
+
+// openToken verifies and decrypts a PASETO v4.local token.
+func openToken(key []byte, token string, implicitAssertion []byte) ([]byte, error) {
+	if !strings.HasPrefix(token, tokenHeader) {
+		return nil, fmt.Errorf("%w: not a v4.local token", ErrInvalidToken)
+	}
+	parts := strings.Split(token[len(tokenHeader):], ".")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("%w: too many parts", ErrInvalidToken)
+	}
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	var footer []byte
+	if len(parts) == 2 {
+		if footer, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+		}
+	}
+	if len(body) < tokenNonceSize+tokenTagSize {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidToken)
+	}
+	nonce, ciphertext, tag := body[:tokenNonceSize], body[tokenNonceSize:len(body)-tokenTagSize], body[len(body)-tokenTagSize:]
+	encryptionKey, counterNonce, authKey, err := splitTokenKey(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	defer setZero(encryptionKey)
+	defer setZero(authKey)
+	expected, err := tokenTag(authKey, nonce, ciphertext, footer, implicitAssertion)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(tag, expected) != 1 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, ErrAuthentication)
+	}
+	payload := make([]byte, len(ciphertext))
+	stream, err := chacha20.NewUnauthenticatedCipher(encryptionKey, counterNonce)
+	if err != nil {
+		return nil, err
+	}
+	stream.XORKeyStream(payload, ciphertext)
+	return payload, nil
+}
+
+// splitTokenKey derives the encryption key, the XChaCha20 nonce and the authentication key of a token nonce.
+func splitTokenKey(key, nonce []byte) (encryptionKey, counterNonce, authKey []byte, err error) {
+	h, err := blake2b.New(chacha20.KeySize+chacha20.NonceSizeX, key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	h.Write([]byte("paseto-encryption-key"))
+	h.Write(nonce)
+	tmp := h.Sum(nil)
+	h, err = blake2b.New(tokenTagSize, key)
+	if err != nil {
+		setZero(tmp)
+		return nil, nil, nil, err
+	}
+	h.Write([]byte("paseto-auth-key-for-aead"))
+	h.Write(nonce)
+	return tmp[:chacha20.KeySize], tmp[chacha20.KeySize:], h.Sum(nil), nil
+}
+
+func tokenTag(authKey, nonce, ciphertext, footer, implicitAssertion []byte) ([]byte, error) {
+	h, err := blake2b.New(tokenTagSize, authKey)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(preAuthEncode([]byte(tokenHeader), nonce, ciphertext, footer, implicitAssertion))
+	return h.Sum(nil), nil
+}
+
+// preAuthEncode is the PAE of PASETO, every piece is prefixed with its little endian length.
+func preAuthEncode(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+	length := make([]byte, 8)
+	binary.LittleEndian.PutUint64(length, uint64(len(pieces)))
+	buf.Write(length)
+	for _, piece := range pieces {
+		// the most significant bit is cleared for compatibility with languages without unsigned integers
+		binary.LittleEndian.PutUint64(length, uint64(len(piece))&^(1<<63))
+		buf.Write(length)
+		buf.Write(piece)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,88 @@
+package cryptox
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenVector(t *testing.T) {
+	// test vector 4-E-1 of the PASETO specification
+	key, err := hex.DecodeString("707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f")
+	assert.NoError(t, err)
+	payload := []byte(`{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`)
+	token, err := sealToken(bytes.NewReader(make([]byte, tokenNonceSize)), key, payload, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvSwscFlAl1pk5HC0e8kApeaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XJ5hOb_4v9RmDkneN0S92dx0OW4pgy7omxgf3S8c3LlQg", token)
+	opened, err := openToken(key, token, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, payload, opened)
+}
+
+func TestIssueAndParseToken(t *testing.T) {
+	keyOne, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	keyTwo, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	c, err := New([]string{keyOne}, nil, nil)
+	assert.NoError(t, err)
+	crypto := c.(TokenIssuer)
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := WithTokenClock(func() time.Time { return now })
+	claims := &TokenClaims{
+		Issuer:    Issuer,
+		Subject:   "user-1",
+		Audience:  "password-reset",
+		Type:      TokenTypeAccess,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(time.Hour),
+		Data:      map[string]string{"email": "test@example.com"},
+	}
+	token, err := crypto.IssueToken(claims, WithTokenFooter(map[string]string{"purpose": "reset"}), WithImplicitAssertion([]byte("row-1")))
+	assert.NoError(t, err)
+	footer, err := TokenFooter(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "reset", footer["purpose"])
+	assert.NotEmpty(t, footer["kid"])
+	assert.NotContains(t, token, "test@example.com")
+	parsed, err := crypto.ParseToken(token, clock, WithAudience("password-reset"), WithTokenType(TokenTypeAccess), WithImplicitAssertion([]byte("row-1")))
+	assert.NoError(t, err)
+	assert.Equal(t, claims, parsed)
+	// expiry, audience, type and implicit assertion are checked
+	_, err = crypto.ParseToken(token, WithTokenClock(func() time.Time { return now.Add(time.Hour) }), WithImplicitAssertion([]byte("row-1")))
+	assert.ErrorIs(t, err, ErrTokenExpired)
+	_, err = crypto.ParseToken(token, clock, WithAudience("email-verification"), WithImplicitAssertion([]byte("row-1")))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = crypto.ParseToken(token, clock, WithTokenType(TokenTypeRefresh), WithImplicitAssertion([]byte("row-1")))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = crypto.ParseToken(token, clock, WithImplicitAssertion([]byte("row-2")))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	// a changed footer fails authentication
+	tampered := token[:len(token)-1] + "A"
+	if tampered == token {
+		tampered = token[:len(token)-1] + "B"
+	}
+	_, err = crypto.ParseToken(tampered, clock, WithImplicitAssertion([]byte("row-1")))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = crypto.IssueToken(&TokenClaims{Subject: "user-1"})
+	assert.Error(t, err)
+	// tokens of lower levels still parse after a key is added, new tokens use the new level
+	assert.NoError(t, c.SetSymmetricEncryptionKeys([]string{keyOne, keyTwo}))
+	_, err = crypto.ParseToken(token, clock, WithImplicitAssertion([]byte("row-1")))
+	assert.NoError(t, err)
+	newToken, err := crypto.IssueToken(claims)
+	assert.NoError(t, err)
+	newFooter, err := TokenFooter(newToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, footer["kid"], newFooter["kid"])
+	other, err := New([]string{keyTwo}, nil, nil)
+	assert.NoError(t, err)
+	_, err = other.(TokenIssuer).ParseToken(newToken, clock)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.NoError(t, c.Close())
+	_, err = crypto.IssueToken(claims)
+	assert.ErrorIs(t, err, ErrClosed)
+}
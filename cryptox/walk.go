@@ -0,0 +1,283 @@
+package cryptox
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+var (
+	stringxType    = reflect.TypeOf(Stringx{})
+	ptrStringxType = reflect.TypeOf(&Stringx{})
+	// errStopWalk can be returned by a visitFunc to end a walk early without failing it
+	errStopWalk = errors.New("stop walk")
+	// containsStringxCache caches the result of mayContainStringx per type
+	containsStringxCache sync.Map
+)
+
+// UnexportedFields decides what happens when a walk reaches an unexported struct field.
+type UnexportedFields int
+
+const (
+	// SkipUnexported silently ignores unexported fields. This is the default.
+	SkipUnexported UnexportedFields = iota
+	// ErrorOnUnexported fails the operation if an unexported field could hold a Stringx.
+	ErrorOnUnexported
+)
+
+// visitFunc is called for every Stringx found in a value. The Stringx is a copy
+// which is written back when the walk is writable and visitFunc returns nil.
+type visitFunc func(path string, stringx *Stringx) error
+
+type visitKey struct {
+	addr uintptr
+	typ  reflect.Type
+	// len tells slices sharing a backing array apart
+	len int
+}
+
+type walker struct {
+	writable   bool
+	unexported UnexportedFields
+	visit      visitFunc
+	visited    map[visitKey]struct{}
+}
+
+// walk finds every Stringx in val - struct fields, embedded structs, pointers, interfaces,
+// maps, slices and arrays - and calls visit for each of them. Values reachable through
+// several pointers (including cycles) are only visited once.
+func (c *defaultCrypto) walk(val interface{}, writable bool, visit visitFunc) error {
+	if val == nil {
+		return errors.New("invalid value - needs to be a pointer to object")
+	}
+	v := reflect.ValueOf(val)
+	if writable {
+		if v.Kind() != reflect.Ptr {
+			return errors.New("invalid value - needs to be a pointer to object")
+		}
+		if v.IsNil() {
+			return errors.New("invalid value - pointer is nil")
+		}
+		if v.Elem().CanSet() == false {
+			return errors.New("cannot update value in interface")
+		}
+	}
+	w := &walker{
+		writable:   writable,
+		unexported: c.unexportedFields,
+		visit:      visit,
+		visited:    map[visitKey]struct{}{},
+	}
+	if err := w.walkValue(v, ""); err != nil && err != errStopWalk {
+		return err
+	}
+	return nil
+}
+
+func (w *walker) walkValue(v reflect.Value, path string) error {
+	if !v.IsValid() || !mayContainStringx(v.Type()) {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			// a nil *Stringx is replaced by an empty Stringx to match how values are stored
+			if w.writable && v.Type() == ptrStringxType && v.CanSet() {
+				v.Set(reflect.New(stringxType))
+			} else {
+				return nil
+			}
+		}
+		return w.walkValue(v.Elem(), path)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		elem := v.Elem()
+		if elem.Kind() == reflect.Ptr {
+			return w.walkValue(elem, path)
+		}
+		// values held by an interface are not addressable - walk a copy and store it back
+		cp := reflect.New(elem.Type()).Elem()
+		cp.Set(elem)
+		if err := w.walkValue(cp, path); err != nil {
+			return err
+		}
+		if w.writable && v.CanSet() {
+			v.Set(cp)
+		}
+		return nil
+	case reflect.Struct:
+		if v.Type() == stringxType {
+			return w.visitStringx(v, path)
+		}
+		if w.seen(v) {
+			return nil
+		}
+		return w.walkStruct(v, path)
+	case reflect.Map:
+		if w.seenReference(v) {
+			return nil
+		}
+		return w.walkMap(v, path)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || w.seenReference(v)) {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := w.walkValue(v.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *walker) walkStruct(v reflect.Value, path string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !mayContainStringx(structField.Type) {
+			continue
+		}
+		fieldPath := joinPath(path, structField.Name)
+		field := v.Field(i)
+		// exported fields of embedded structs are reachable even when the embedded type is not
+		embeddedType := structField.Type
+		if embeddedType.Kind() == reflect.Ptr {
+			embeddedType = embeddedType.Elem()
+		}
+		embeddedStruct := structField.Anonymous && embeddedType.Kind() == reflect.Struct
+		if structField.PkgPath != "" && !embeddedStruct {
+			if w.unexported == ErrorOnUnexported {
+				return fmt.Errorf("unexported field %s cannot be processed", fieldPath)
+			}
+			continue
+		}
+		if err := w.walkValue(field, fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) walkMap(v reflect.Value, path string) error {
+	if v.IsNil() {
+		return nil
+	}
+	keys := v.MapKeys()
+	// sort keys so paths and errors are reported in a stable order
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	for _, key := range keys {
+		// map values are not addressable - walk a copy and store it back
+		cp := reflect.New(v.Type().Elem()).Elem()
+		cp.Set(v.MapIndex(key))
+		keyPath := path + "[" + fmt.Sprint(key.Interface()) + "]"
+		if path == "" {
+			// keys of a top level map read like field names
+			keyPath = fmt.Sprint(key.Interface())
+		}
+		if err := w.walkValue(cp, keyPath); err != nil {
+			return err
+		}
+		if w.writable {
+			v.SetMapIndex(key, cp)
+		}
+	}
+	return nil
+}
+
+func (w *walker) visitStringx(v reflect.Value, path string) error {
+	if w.seen(v) {
+		return nil
+	}
+	if !v.CanInterface() {
+		if w.unexported == ErrorOnUnexported {
+			return fmt.Errorf("unexported field %s cannot be processed", path)
+		}
+		return nil
+	}
+	stringx := v.Interface().(Stringx)
+	if err := w.visit(path, &stringx); err != nil {
+		return err
+	}
+	if w.writable {
+		if v.CanSet() == false {
+			return fmt.Errorf("cannot update value at %s", path)
+		}
+		v.Set(reflect.ValueOf(stringx))
+	}
+	return nil
+}
+
+// seen marks addressable values as visited and reports if they have been visited before.
+func (w *walker) seen(v reflect.Value) bool {
+	if !v.CanAddr() {
+		return false
+	}
+	key := visitKey{addr: v.UnsafeAddr(), typ: v.Type()}
+	if _, ok := w.visited[key]; ok {
+		return true
+	}
+	w.visited[key] = struct{}{}
+	return false
+}
+
+// seenReference marks maps and slices as visited and reports if they have been visited before. Maps and slices
+// can contain themselves through interfaces, so they are tracked by the data they point to.
+func (w *walker) seenReference(v reflect.Value) bool {
+	if v.IsNil() || v.Len() == 0 {
+		return false
+	}
+	key := visitKey{addr: v.Pointer(), typ: v.Type(), len: v.Len()}
+	if _, ok := w.visited[key]; ok {
+		return true
+	}
+	w.visited[key] = struct{}{}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// mayContainStringx reports if a value of type t can hold a Stringx anywhere inside it.
+func mayContainStringx(t reflect.Type) bool {
+	if contains, ok := containsStringxCache.Load(t); ok {
+		return contains.(bool)
+	}
+	contains := typeMayContainStringx(t, map[reflect.Type]bool{})
+	containsStringxCache.Store(t, contains)
+	return contains
+}
+
+func typeMayContainStringx(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if t == stringxType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return typeMayContainStringx(t.Elem(), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			return false
+		}
+		visiting[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			if typeMayContainStringx(t.Field(i).Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package cryptox
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Embedded struct {
+	Embedded Stringx
+}
+
+type hiddenEmbedded struct {
+	Promoted Stringx
+}
+
+type Node struct {
+	Embedded
+	hiddenEmbedded
+	Name     Stringx
+	Any      interface{}
+	Next     *Node
+	Children []*Node
+	Values   map[string]interface{}
+	Array    [2]Stringx
+	hidden   Stringx
+}
+
+func newTestCrypto(t testing.TB, opts ...Option) Crypto {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	c, err := New([]string{key}, nil, nil, opts...)
+	assert.NoError(t, err)
+	return c
+}
+
+// collectBodies returns the body of every non empty Stringx in val keyed by its path.
+func collectBodies(t testing.TB, c Crypto, val interface{}) map[string]string {
+	bodies := map[string]string{}
+	assert.NoError(t, c.(*defaultCrypto).walk(val, false, func(path string, stringx *Stringx) error {
+		if stringx.Body != "" {
+			bodies[path] = stringx.Body
+		}
+		return nil
+	}))
+	return bodies
+}
+
+func TestWalkCyclesInterfacesAndEmbedded(t *testing.T) {
+	c := newTestCrypto(t)
+	node := &Node{
+		Embedded:       Embedded{Embedded: Stringx{Body: "embedded"}},
+		hiddenEmbedded: hiddenEmbedded{Promoted: Stringx{Body: "promoted"}},
+		Name:           Stringx{Body: "name"},
+		Any:            Stringx{Body: "any"},
+		Values: map[string]interface{}{
+			"value":   Stringx{Body: "map value"},
+			"pointer": &Stringx{Body: "map pointer"},
+			"plain":   "not a stringx",
+		},
+		Array:  [2]Stringx{{Body: "first"}, {Body: "second"}},
+		hidden: Stringx{Body: "hidden"},
+	}
+	// self referencing pointer graph
+	node.Next = node
+	node.Children = []*Node{node, {Name: Stringx{Body: "child"}}}
+	before := collectBodies(t, c, node)
+	assert.Len(t, before, 9)
+	assert.NoError(t, c.Encrypt(node))
+	encrypted := collectBodies(t, c, node)
+	for path, body := range encrypted {
+		assert.NotEqual(t, before[path], body, path)
+	}
+	assert.Equal(t, "hidden", node.hidden.Body)
+	assert.Equal(t, "not a stringx", node.Values["plain"])
+	assert.NoError(t, c.Decrypt(node))
+	assert.Equal(t, before, collectBodies(t, c, node))
+	assert.Equal(t, "any", node.Any.(Stringx).Body)
+	assert.Equal(t, "map pointer", node.Values["pointer"].(*Stringx).Body)
+}
+
+func TestWalkSelfContainingMapsAndSlices(t *testing.T) {
+	c := newTestCrypto(t)
+	values := map[string]interface{}{"name": Stringx{Body: "name"}}
+	values["self"] = values
+	list := []interface{}{&Stringx{Body: "first"}, nil}
+	list[1] = list
+	values["list"] = list
+	// a slice sharing the backing array of list but with another length is walked on its own
+	values["head"] = list[:1]
+	before := collectBodies(t, c, &values)
+	// the shared *Stringx is visited once, at the first path in key order
+	assert.Equal(t, map[string]string{"name": "name", "head[0]": "first"}, before)
+	assert.NoError(t, c.Encrypt(&values))
+	assert.NotEqual(t, "name", values["name"].(Stringx).Body)
+	report, err := c.Inspect(&values)
+	assert.NoError(t, err)
+	assert.Len(t, report.Fields, 2)
+	assert.NoError(t, c.Decrypt(&values))
+	assert.Equal(t, before, collectBodies(t, c, &values))
+	assert.Equal(t, "first", list[0].(*Stringx).Body)
+}
+
+func TestWalkUnexportedFields(t *testing.T) {
+	c := newTestCrypto(t, WithUnexportedFields(ErrorOnUnexported))
+	err := c.Encrypt(&Node{hidden: Stringx{Body: "hidden"}})
+	assert.Error(t, err)
+	// unexported fields which can never hold a Stringx are always skipped
+	type withCounter struct {
+		Name    Stringx
+		counter int
+	}
+	assert.NoError(t, c.Encrypt(&withCounter{Name: Stringx{Body: "name"}}))
+}
+
+func TestWalkInvalidValues(t *testing.T) {
+	c := newTestCrypto(t)
+	var nilNode *Node
+	assert.Error(t, c.Encrypt(nil))
+	assert.Error(t, c.Encrypt(nilNode))
+	assert.Error(t, c.Encrypt(Node{}))
+	assert.NoError(t, c.Encrypt(&map[string]Stringx{"one": {Body: "one"}}))
+}
+
+var fuzzFieldTypes = []reflect.Type{
+	reflect.TypeOf(Stringx{}),
+	reflect.TypeOf(&Stringx{}),
+	reflect.TypeOf([]Stringx{}),
+	reflect.TypeOf(map[string]*Stringx{}),
+	reflect.TypeOf((*interface{})(nil)).Elem(),
+	reflect.TypeOf(&Node{}),
+	reflect.TypeOf(""),
+}
+
+// fuzzValues builds arbitrary values from the fuzz input. Pointers are reused so
+// the resulting graphs contain shared values and cycles.
+type fuzzValues struct {
+	data  []byte
+	nodes []*Node
+	depth int
+}
+
+func (f *fuzzValues) next() int {
+	if len(f.data) == 0 {
+		return 0
+	}
+	b := f.data[0]
+	f.data = f.data[1:]
+	return int(b)
+}
+
+func (f *fuzzValues) stringx() Stringx {
+	return Stringx{Body: fmt.Sprintf("body-%d", f.next())}
+}
+
+func (f *fuzzValues) value(t reflect.Type) reflect.Value {
+	f.depth++
+	defer func() { f.depth-- }()
+	v := reflect.New(t).Elem()
+	if f.depth > 5 {
+		return v
+	}
+	switch t {
+	case fuzzFieldTypes[0]:
+		v.Set(reflect.ValueOf(f.stringx()))
+	case fuzzFieldTypes[1]:
+		if f.next()%3 != 0 {
+			s := f.stringx()
+			v.Set(reflect.ValueOf(&s))
+		}
+	case fuzzFieldTypes[2]:
+		for i := f.next() % 3; i > 0; i-- {
+			v.Set(reflect.Append(v, reflect.ValueOf(f.stringx())))
+		}
+	case fuzzFieldTypes[3]:
+		m := map[string]*Stringx{}
+		for i := f.next() % 3; i > 0; i-- {
+			s := f.stringx()
+			m[fmt.Sprint(i)] = &s
+		}
+		v.Set(reflect.ValueOf(m))
+	case fuzzFieldTypes[4]:
+		switch f.next() % 4 {
+		case 1:
+			v.Set(reflect.ValueOf(f.stringx()))
+		case 2:
+			s := f.stringx()
+			v.Set(reflect.ValueOf(&s))
+		case 3:
+			v.Set(f.value(f.structType()))
+		}
+	case fuzzFieldTypes[5]:
+		v.Set(reflect.ValueOf(f.node()))
+	case fuzzFieldTypes[6]:
+		v.SetString(fmt.Sprint(f.next()))
+	default:
+		for i := 0; i < t.NumField(); i++ {
+			v.Field(i).Set(f.value(t.Field(i).Type))
+		}
+	}
+	return v
+}
+
+func (f *fuzzValues) node() *Node {
+	choice := f.next()
+	if len(f.nodes) > 0 && choice%2 == 0 {
+		return f.nodes[choice%len(f.nodes)]
+	}
+	n := &Node{
+		Embedded:       Embedded{Embedded: f.stringx()},
+		hiddenEmbedded: hiddenEmbedded{Promoted: f.stringx()},
+		Name:           f.stringx(),
+		hidden:         f.stringx(),
+		Array:          [2]Stringx{f.stringx(), f.stringx()},
+	}
+	f.nodes = append(f.nodes, n)
+	n.Any = f.value(fuzzFieldTypes[4]).Interface()
+	n.Next = f.value(fuzzFieldTypes[5]).Interface().(*Node)
+	if f.next()%2 == 0 {
+		n.Values = map[string]interface{}{"any": f.value(fuzzFieldTypes[4]).Interface()}
+	}
+	return n
+}
+
+func (f *fuzzValues) structType() reflect.Type {
+	var fields []reflect.StructField
+	for i := f.next() % 5; i >= 0; i-- {
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Field%d", i),
+			Type: fuzzFieldTypes[f.next()%len(fuzzFieldTypes)],
+		})
+	}
+	return reflect.StructOf(fields)
+}
+
+func FuzzWalk(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	f.Add([]byte{4, 0, 4, 4, 2, 5, 4, 1, 0, 0, 3, 3, 7, 2, 1})
+	c := newTestCrypto(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		values := &fuzzValues{data: data}
+		val := reflect.New(values.structType())
+		val.Elem().Set(values.value(val.Elem().Type()))
+		before := collectBodies(t, c, val.Interface())
+		assert.NoError(t, c.Encrypt(val.Interface()))
+		for path, body := range collectBodies(t, c, val.Interface()) {
+			if before[path] != "" {
+				assert.NotEqual(t, before[path], body, path)
+			}
+		}
+		assert.NoError(t, c.Decrypt(val.Interface()))
+		assert.Equal(t, before, collectBodies(t, c, val.Interface()))
+	})
+}
@@ -0,0 +1,188 @@
+package cryptox
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// AlgorithmX25519ChaCha20Poly1305 is a sealed box: an ephemeral X25519 key agreement with the public key
+// of the recipient, HKDF-SHA256 and ChaCha20-Poly1305. It is stored in Stringx.PublicKeyAlgorithm.
+const AlgorithmX25519ChaCha20Poly1305 = "X25519-ChaCha20-Poly1305"
+
+const (
+	X25519KeySize    = curve25519.PointSize
+	sealedBoxInfo    = "cryptox x25519 sealed box"
+	sealedBoxTagSize = 16
+)
+
+// X25519PublicKey is the public key of an X25519 key pair.
+type X25519PublicKey [X25519KeySize]byte
+
+// X25519PrivateKey is the private key of an X25519 key pair.
+type X25519PrivateKey [X25519KeySize]byte
+
+// WithX25519Keys encrypts the public key layer of new values with an X25519 sealed box instead of RSA-OAEP.
+// The private key may be nil for services which only encrypt. Values encrypted with RSA are still
+// decrypted with the private key passed to New and Upgradeble reports them.
+func WithX25519Keys(publicKey *X25519PublicKey, privateKey *X25519PrivateKey) Option {
+	return func(c *defaultCrypto) {
+		c.keys.x25519PublicKey = publicKey
+		if privateKey != nil {
+			privateKeyCopy := *privateKey
+			c.keys.x25519PrivateKey = &privateKeyCopy
+		}
+	}
+}
+
+func GenerateX25519KeyPair() (*X25519PrivateKey, *X25519PublicKey, error) {
+	return generateX25519KeyPair(rand.Reader)
+}
+
+func generateX25519KeyPair(random io.Reader) (*X25519PrivateKey, *X25519PublicKey, error) {
+	privateKey := &X25519PrivateKey{}
+	if _, err := io.ReadFull(random, privateKey[:]); err != nil {
+		return nil, nil, err
+	}
+	publicKey, err := privateKey.PublicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, publicKey, nil
+}
+
+// PublicKey returns the public key belonging to k.
+func (k *X25519PrivateKey) PublicKey() (*X25519PublicKey, error) {
+	point, err := curve25519.X25519(k[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	publicKey := &X25519PublicKey{}
+	copy(publicKey[:], point)
+	return publicKey, nil
+}
+
+// String returns the hex encoded key.
+func (k *X25519PublicKey) String() string {
+	return hex.EncodeToString(k[:])
+}
+
+// ParseX25519PublicKey parses a hex encoded X25519 public key.
+func ParseX25519PublicKey(s string) (*X25519PublicKey, error) {
+	key := &X25519PublicKey{}
+	if err := parseX25519Key(s, key[:]); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ParseX25519PrivateKey parses a hex encoded X25519 private key.
+func ParseX25519PrivateKey(s string) (*X25519PrivateKey, error) {
+	key := &X25519PrivateKey{}
+	if err := parseX25519Key(s, key[:]); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func parseX25519Key(s string, key []byte) error {
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	defer setZero(decoded)
+	if len(decoded) != X25519KeySize {
+		return fmt.Errorf("invalid x25519 key size: %d", len(decoded))
+	}
+	copy(key, decoded)
+	return nil
+}
+
+// sealX25519 encrypts plaintext for publicKey. The result is the ephemeral public key followed by the ciphertext.
+func sealX25519(random io.Reader, publicKey *X25519PublicKey, plaintext []byte) ([]byte, error) {
+	ephemeralPrivateKey, ephemeralPublicKey, err := generateX25519KeyPair(random)
+	if err != nil {
+		return nil, err
+	}
+	defer setZero(ephemeralPrivateKey[:])
+	aead, err := sealedBoxCipher(ephemeralPrivateKey[:], publicKey[:], ephemeralPublicKey, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	// every message uses a new ephemeral key, so the key is never reused and a zero nonce is safe
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(append([]byte(nil), ephemeralPublicKey[:]...), nonce, plaintext, nil), nil
+}
+
+// openX25519 decrypts a sealed box created by sealX25519.
+func openX25519(privateKey *X25519PrivateKey, sealed []byte) ([]byte, error) {
+	if len(sealed) < X25519KeySize+sealedBoxTagSize {
+		return nil, fmt.Errorf("%w: sealed box too short", ErrMalformedCiphertext)
+	}
+	ephemeralPublicKey := &X25519PublicKey{}
+	copy(ephemeralPublicKey[:], sealed[:X25519KeySize])
+	publicKey, err := privateKey.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	aead, err := sealedBoxCipher(privateKey[:], ephemeralPublicKey[:], ephemeralPublicKey, publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthentication, err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	plaintext, err := aead.Open(nil, nonce, sealed[X25519KeySize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthentication, err)
+	}
+	return plaintext, nil
+}
+
+// sealedBoxCipher derives the key of a sealed box from the shared secret of scalar and point, bound to both public keys.
+func sealedBoxCipher(scalar, point []byte, ephemeralPublicKey, publicKey *X25519PublicKey) (cipher.AEAD, error) {
+	// X25519 fails for low order points, which would give an all zero shared secret
+	shared, err := curve25519.X25519(scalar, point)
+	if err != nil {
+		return nil, err
+	}
+	defer setZero(shared)
+	salt := append(append([]byte(nil), ephemeralPublicKey[:]...), publicKey[:]...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	defer setZero(key)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(sealedBoxInfo)), key); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(key)
+}
+
+// publicKeyAlgorithm returns the algorithm used for the public key layer of new values or an empty string if there is none.
+func (c *defaultCrypto) publicKeyAlgorithm() string {
+	if len(c.keys.recipients) > 0 {
+		return AlgorithmRecipients
+	}
+	if c.keys.x25519PublicKey != nil {
+		return AlgorithmX25519ChaCha20Poly1305
+	}
+	if c.keys.publicKey != nil {
+		return AlgorithmRSAOAEPSHA256
+	}
+	return ""
+}
+
+// publicKeyAlgorithm returns the algorithm of the public key layer of stringx. An empty
+// Stringx.PublicKeyAlgorithm is RSA-OAEP, which was the only algorithm before X25519 was added.
+func (stringx *Stringx) publicKeyAlgorithm() string {
+	if !stringx.PublicKeyEncrypted {
+		return ""
+	}
+	if stringx.PublicKeyAlgorithm == "" {
+		return AlgorithmRSAOAEPSHA256
+	}
+	return stringx.PublicKeyAlgorithm
+}
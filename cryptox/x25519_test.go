@@ -0,0 +1,52 @@
+package cryptox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestX25519(t *testing.T) {
+	key, err := GenerateSymmetricKey(32, AlphaNum)
+	assert.NoError(t, err)
+	rsaPrivateKey, rsaPublicKey, err := GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	x25519PrivateKey, x25519PublicKey, err := GenerateX25519KeyPair()
+	assert.NoError(t, err)
+	parsed, err := ParseX25519PublicKey(x25519PublicKey.String())
+	assert.NoError(t, err)
+	assert.Equal(t, x25519PublicKey, parsed)
+	rsaCrypto, err := New([]string{key}, rsaPublicKey, rsaPrivateKey)
+	assert.NoError(t, err)
+	x25519Crypto, err := New([]string{key}, nil, rsaPrivateKey, WithX25519Keys(x25519PublicKey, x25519PrivateKey))
+	assert.NoError(t, err)
+	// values encrypted with both algorithms coexist
+	rsaValue := &InnerStruct{One: Stringx{Body: "rsa"}}
+	assert.NoError(t, rsaCrypto.Encrypt(rsaValue))
+	assert.Empty(t, rsaValue.One.PublicKeyAlgorithm)
+	x25519Value := &InnerStruct{One: Stringx{Body: "x25519"}}
+	assert.NoError(t, x25519Crypto.Encrypt(x25519Value))
+	assert.True(t, x25519Value.One.PublicKeyEncrypted)
+	assert.Equal(t, AlgorithmX25519ChaCha20Poly1305, x25519Value.One.PublicKeyAlgorithm)
+	report, err := x25519Crypto.Inspect(rsaValue)
+	assert.NoError(t, err)
+	assert.Equal(t, AlgorithmRSAOAEPSHA256, report.Fields[0].PublicKeyAlgorithm)
+	assert.True(t, report.Fields[0].Upgradeable)
+	upgradeable, err := x25519Crypto.Upgradeble(x25519Value)
+	assert.NoError(t, err)
+	assert.False(t, upgradeable)
+	tampered := *x25519Value
+	assert.NoError(t, x25519Crypto.Decrypt(rsaValue))
+	assert.Equal(t, "rsa", rsaValue.One.Body)
+	assert.NoError(t, x25519Crypto.Decrypt(x25519Value))
+	assert.Equal(t, "x25519", x25519Value.One.Body)
+	// the sealed box is authenticated
+	symmetricCrypto, err := New([]string{key}, nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, symmetricCrypto.Decrypt(&tampered))
+	body := []byte(tampered.One.Body)
+	body[len(body)-1] ^= 1
+	tampered.One.Body = string(body)
+	tampered.One.EncryptionLevel = 0
+	assert.ErrorIs(t, x25519Crypto.Decrypt(&tampered), ErrAuthentication)
+}
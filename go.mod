@@ -3,9 +3,12 @@ module github.com/nuntiodev/x
 go 1.18
 
 require (
+	github.com/golang-jwt/jwt/v4 v4.4.1
+	github.com/klauspost/compress v1.13.6
 	github.com/ory/dockertest/v3 v3.8.1
 	go.mongodb.org/mongo-driver v1.9.0
 	go.uber.org/zap v1.21.0
+	golang.org/x/crypto v0.0.0-20201216223049-8b5274cf687f
 	google.golang.org/protobuf v1.28.0
 )
 
@@ -23,11 +26,9 @@ require (
 	github.com/docker/go-units v0.4.0 // indirect
 	github.com/go-stack/stack v1.8.0 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
-	github.com/golang-jwt/jwt/v4 v4.4.1 // indirect
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
 	github.com/imdario/mergo v0.3.12 // indirect
-	github.com/klauspost/compress v1.13.6 // indirect
 	github.com/mitchellh/mapstructure v1.4.1 // indirect
 	github.com/moby/term v0.0.0-20201216013528-df9cb8a40635 // indirect
 	github.com/nuntiodev/hera-sdks v0.2.96 // indirect
@@ -48,7 +49,6 @@ require (
 	github.com/youmark/pkcs8 v0.0.0-20181117223130-1be2e3e5546d // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
-	golang.org/x/crypto v0.0.0-20201216223049-8b5274cf687f // indirect
 	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4 // indirect
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
 	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
@@ -1,5 +1,11 @@
 package hashx
 
+import "golang.org/x/crypto/bcrypt"
+
+// ErrMismatchedHashAndCode is returned by CompareHashCode when the code does not match the hash.
+// It is the error of bcrypt, so existing checks against bcrypt.ErrMismatchedHashAndPassword keep working.
+var ErrMismatchedHashAndCode = bcrypt.ErrMismatchedHashAndPassword
+
 type Hashx interface {
 	CreateHash(code string) (string, error)
 	CompareHashCode(hash, code string) error
@@ -2,22 +2,39 @@ package hashx
 
 import (
 	"crypto/rand"
-	"github.com/nuntiodev/x/cryptox"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/crypto/scrypt"
 )
 
 // Some constants used throughout the code
 const (
-	N                = 16384
-	r                = 8
-	p                = 1
-	metadataLenBytes = 60
-	saltLenBytes     = 16
+	N            = 16384
+	r            = 8
+	p            = 1
+	keyLenBytes  = 32
+	saltLenBytes = 16
+	// maxLogN, maxR and maxP bound the cost of hashes read from storage
+	maxLogN = 20
+	maxR    = 32
+	maxP    = 16
 )
 
+// scryptPrefix starts hashes in the PHC string format: $scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<hash>
+// with salt and hash base64 encoded without padding.
+const scryptPrefix = "$scrypt$"
+
+var errInvalidScryptHash = errors.New("invalid scrypt hash")
+
 type scryptx struct{}
 
+// NewScrypt returns a Hashx creating scrypt hashes. CompareHashCode also accepts bcrypt hashes,
+// so codes hashed before the switch to scrypt keep working.
 func NewScrypt() Hashx {
 	return &scryptx{}
 }
@@ -32,26 +49,61 @@ func generateSalt() ([]byte, error) {
 }
 
 func (h *scryptx) CreateHash(code string) (string, error) {
-	salt, err := cryptox.GenerateSymmetricKey(32, cryptox.AlphaNum)
+	salt, err := generateSalt()
+	if err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key([]byte(code), salt, N, r, p, keyLenBytes)
 	if err != nil {
 		return "", err
 	}
-	//https://github.com/agnivade/easy-scrypt/blob/88dceb2547a14794232f2cb1f17ffaa71ab108c5/scrypt.go#L131
-	/*
-		Firebase:
-		hash_config {
-		  algorithm: SCRYPT,
-		  base64_signer_key: jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA==,
-		  base64_salt_separator: Bw==,
-		  rounds: 8,
-		  mem_cost: 14,
-		}
-	*/
-	scrypt.Key([]byte(code), []byte(salt), 1<<15, 8, 1, 32)
-	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
-	return string(hash), err
+	return fmt.Sprintf("%sln=%d,r=%d,p=%d$%s$%s", scryptPrefix, logN(N), r, p,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
 }
 
 func (h *scryptx) CompareHashCode(hash, code string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(code))
+	if !strings.HasPrefix(hash, scryptPrefix) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(code))
+	}
+	parts := strings.Split(strings.TrimPrefix(hash, scryptPrefix), "$")
+	if len(parts) != 3 {
+		return errInvalidScryptHash
+	}
+	var ln, hashR, hashP int
+	if _, err := fmt.Sscanf(parts[0], "ln=%d,r=%d,p=%d", &ln, &hashR, &hashP); err != nil {
+		return errInvalidScryptHash
+	}
+	// Sscanf stops at the last verb, so the parameters are formatted again to reject trailing input
+	if parts[0] != fmt.Sprintf("ln=%d,r=%d,p=%d", ln, hashR, hashP) {
+		return errInvalidScryptHash
+	}
+	if ln < 1 || ln > maxLogN || hashR < 1 || hashR > maxR || hashP < 1 || hashP > maxP {
+		return errInvalidScryptHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil || len(salt) == 0 {
+		return errInvalidScryptHash
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil || len(expected) != keyLenBytes {
+		return errInvalidScryptHash
+	}
+	key, err := scrypt.Key([]byte(code), salt, 1<<ln, hashR, hashP, keyLenBytes)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(key, expected) != 1 {
+		return ErrMismatchedHashAndCode
+	}
+	return nil
+}
+
+// logN returns the base 2 logarithm of the power of two n.
+func logN(n int) int {
+	ln := 0
+	for n > 1 {
+		n >>= 1
+		ln++
+	}
+	return ln
 }
@@ -0,0 +1,43 @@
+package hashx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestScrypt(t *testing.T) {
+	h := NewScrypt()
+	hash, err := h.CreateHash("123456")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$scrypt$ln=14,r=8,p=1$"))
+	assert.NoError(t, h.CompareHashCode(hash, "123456"))
+	assert.ErrorIs(t, h.CompareHashCode(hash, "654321"), ErrMismatchedHashAndCode)
+	// every hash has its own salt
+	other, err := h.CreateHash("123456")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+	// hashes with other parameters are compared with their own parameters
+	assert.NoError(t, h.CompareHashCode("$scrypt$ln=4,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$5f/Vi+XRWGUNGScbsma6KJ4zLFIke/NJsrvr7lQLAyA", "password"))
+	assert.Error(t, h.CompareHashCode("$scrypt$ln=4,r=8,p=1$c2FsdA", "password"))
+	// hashes with unsafe or malformed parameters are rejected before deriving a key
+	for _, invalid := range []string{
+		"$scrypt$ln=40,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$5f/Vi+XRWGUNGScbsma6KJ4zLFIke/NJsrvr7lQLAyA",
+		"$scrypt$ln=4,r=64,p=1$c2FsdHNhbHRzYWx0c2FsdA$5f/Vi+XRWGUNGScbsma6KJ4zLFIke/NJsrvr7lQLAyA",
+		"$scrypt$ln=4,r=8,p=32$c2FsdHNhbHRzYWx0c2FsdA$5f/Vi+XRWGUNGScbsma6KJ4zLFIke/NJsrvr7lQLAyA",
+		"$scrypt$ln=4,r=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$5f/Vi+XRWGUNGScbsma6KJ4zLFIke/NJsrvr7lQLAyA",
+		"$scrypt$ln=4,r=8,p=1,x=1$c2FsdHNhbHRzYWx0c2FsdA$5f/Vi+XRWGUNGScbsma6KJ4zLFIke/NJsrvr7lQLAyA",
+		"$scrypt$ln=4,r=8,p=1$$5f/Vi+XRWGUNGScbsma6KJ4zLFIke/NJsrvr7lQLAyA",
+		"$scrypt$ln=4,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$5f/Vi+XRWGUNGScbsma6KJ4zLFIke/NJ",
+		"$scrypt$ln=4,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$c2FsdA",
+	} {
+		assert.ErrorIs(t, h.CompareHashCode(invalid, "password"), errInvalidScryptHash, invalid)
+	}
+	// bcrypt hashes stored before keep working
+	bcryptHash, err := NewBcrypt().CreateHash("123456")
+	assert.NoError(t, err)
+	assert.NoError(t, h.CompareHashCode(bcryptHash, "123456"))
+	assert.ErrorIs(t, h.CompareHashCode(bcryptHash, "654321"), bcrypt.ErrMismatchedHashAndPassword)
+}
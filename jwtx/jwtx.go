@@ -0,0 +1,264 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/nuntiodev/x/cryptox"
+)
+
+// Algorithm is the JWS algorithm used to sign tokens.
+type Algorithm string
+
+const (
+	RS256 Algorithm = "RS256"
+	PS256 Algorithm = "PS256"
+	EdDSA Algorithm = "EdDSA"
+)
+
+const (
+	// ClaimTokenType holds the type of a token, cryptox.TokenTypeAccess or cryptox.TokenTypeRefresh
+	ClaimTokenType = "token_type"
+)
+
+var (
+	// reservedClaims cannot be set as custom claims
+	reservedClaims = map[string]bool{"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true, ClaimTokenType: true}
+)
+
+// Key is a key used to sign or verify tokens. Keys used to sign need the PrivateKey, keys which are only
+// published and verified can set the PublicKey instead. The ID defaults to the RFC 7638 thumbprint.
+type Key struct {
+	ID         string
+	Algorithm  Algorithm
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+type Signer interface {
+	// Issue signs a token of tokenType for subject which expires after ttl. Custom claims are added next to the standard claims.
+	Issue(tokenType, subject string, audience []string, ttl time.Duration, custom map[string]interface{}) (string, error)
+	IssueAccessToken(subject string, audience []string, custom map[string]interface{}) (string, error)
+	IssueRefreshToken(subject string, audience []string, custom map[string]interface{}) (string, error)
+	// Sign signs arbitrary claims with the signing key.
+	Sign(claims jwt.Claims) (string, error)
+	// Verify checks the signature, expiry, issuer, token type and audience of a token issued by the signer.
+	// An empty tokenType or audience is not checked.
+	Verify(token, tokenType, audience string) (jwt.MapClaims, error)
+	Keyfunc(token *jwt.Token) (interface{}, error)
+	JWKS() *JWKS
+}
+
+type defaultSigner struct {
+	signingKey       *Key
+	keys             map[string]*Key
+	verificationKeys []Key
+	issuer           string
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+	now              func() time.Time
+	random           io.Reader
+}
+
+// Option configures optional behaviour of a Signer created with New.
+type Option func(s *defaultSigner)
+
+// WithIssuer sets the iss claim of issued tokens, it defaults to cryptox.Issuer.
+func WithIssuer(issuer string) Option {
+	return func(s *defaultSigner) {
+		s.issuer = issuer
+	}
+}
+
+// WithTTL sets how long access and refresh tokens are valid, it defaults to 15 minutes and 30 days.
+func WithTTL(accessToken, refreshToken time.Duration) Option {
+	return func(s *defaultSigner) {
+		s.accessTokenTTL = accessToken
+		s.refreshTokenTTL = refreshToken
+	}
+}
+
+// WithVerificationKeys publishes and accepts keys next to the signing key, for example the previous key during rotation.
+func WithVerificationKeys(keys ...Key) Option {
+	return func(s *defaultSigner) {
+		s.verificationKeys = append(s.verificationKeys, keys...)
+	}
+}
+
+// WithClock sets the clock used for the time claims, it defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(s *defaultSigner) {
+		s.now = now
+	}
+}
+
+func New(signingKey Key, opts ...Option) (Signer, error) {
+	if signingKey.PrivateKey == nil {
+		return nil, errors.New("signing key has no private key")
+	}
+	s := &defaultSigner{
+		keys:            map[string]*Key{},
+		issuer:          cryptox.Issuer,
+		accessTokenTTL:  time.Minute * 15,
+		refreshTokenTTL: time.Hour * 24 * 30,
+		now:             time.Now,
+		random:          rand.Reader,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for i := range s.verificationKeys {
+		if err := s.addKey(&s.verificationKeys[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.addKey(&signingKey); err != nil {
+		return nil, err
+	}
+	s.signingKey = s.keys[signingKey.ID]
+	return s, nil
+}
+
+func (s *defaultSigner) addKey(key *Key) error {
+	if key.PrivateKey != nil {
+		key.PublicKey = key.PrivateKey.Public()
+	}
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if key.ID == "" {
+		thumbprint, err := Thumbprint(key.PublicKey)
+		if err != nil {
+			return err
+		}
+		key.ID = thumbprint
+	}
+	if _, ok := s.keys[key.ID]; ok {
+		return fmt.Errorf("duplicate key id %s", key.ID)
+	}
+	s.keys[key.ID] = key
+	return nil
+}
+
+// checkKey makes sure the algorithm of a key matches its type.
+func checkKey(key *Key) error {
+	switch key.Algorithm {
+	case RS256, PS256:
+		if _, ok := key.PublicKey.(*rsa.PublicKey); !ok {
+			return fmt.Errorf("%s needs an rsa key", key.Algorithm)
+		}
+	case EdDSA:
+		if _, ok := key.PublicKey.(ed25519.PublicKey); !ok {
+			return fmt.Errorf("%s needs an ed25519 key", key.Algorithm)
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", key.Algorithm)
+	}
+	return nil
+}
+
+func (s *defaultSigner) Issue(tokenType, subject string, audience []string, ttl time.Duration, custom map[string]interface{}) (string, error) {
+	if ttl <= 0 {
+		return "", errors.New("ttl must be positive")
+	}
+	jti := make([]byte, 16)
+	if _, err := io.ReadFull(s.random, jti); err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{}
+	for name, value := range custom {
+		if reservedClaims[name] {
+			return "", fmt.Errorf("custom claim %s is reserved", name)
+		}
+		claims[name] = value
+	}
+	now := s.now()
+	claims["iss"] = s.issuer
+	claims["sub"] = subject
+	if len(audience) > 0 {
+		claims["aud"] = audience
+	}
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["jti"] = hex.EncodeToString(jti)
+	if tokenType != "" {
+		claims[ClaimTokenType] = tokenType
+	}
+	return s.Sign(claims)
+}
+
+func (s *defaultSigner) IssueAccessToken(subject string, audience []string, custom map[string]interface{}) (string, error) {
+	return s.Issue(cryptox.TokenTypeAccess, subject, audience, s.accessTokenTTL, custom)
+}
+
+func (s *defaultSigner) IssueRefreshToken(subject string, audience []string, custom map[string]interface{}) (string, error) {
+	return s.Issue(cryptox.TokenTypeRefresh, subject, audience, s.refreshTokenTTL, custom)
+}
+
+func (s *defaultSigner) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(signingMethod(s.signingKey.Algorithm), claims)
+	token.Header["kid"] = s.signingKey.ID
+	return token.SignedString(s.signingKey.PrivateKey)
+}
+
+func (s *defaultSigner) Verify(token, tokenType, audience string) (jwt.MapClaims, error) {
+	if token == "" {
+		return nil, errors.New("token is empty")
+	}
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{string(RS256), string(PS256), string(EdDSA)}))
+	if _, err := parser.ParseWithClaims(token, claims, s.Keyfunc); err != nil {
+		return nil, err
+	}
+	// the time claims were checked against jwt.TimeFunc, check them against the clock of the signer as well
+	now := s.now().Unix()
+	if !claims.VerifyExpiresAt(now, true) {
+		return nil, errors.New("token is expired")
+	}
+	if !claims.VerifyNotBefore(now, false) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if !claims.VerifyIssuer(s.issuer, true) {
+		return nil, errors.New("token has another issuer")
+	}
+	if tokenType != "" && claims[ClaimTokenType] != tokenType {
+		return nil, fmt.Errorf("token is not a %s", tokenType)
+	}
+	if audience != "" && !claims.VerifyAudience(audience, true) {
+		return nil, errors.New("token has another audience")
+	}
+	return claims, nil
+}
+
+// Keyfunc returns the public key of the kid header. It can be passed to jwt.Parse and rejects tokens whose
+// alg header does not match the algorithm of the key.
+func (s *defaultSigner) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != string(key.Algorithm) {
+		return nil, fmt.Errorf("key %s is not used with %s", kid, token.Method.Alg())
+	}
+	return key.PublicKey, nil
+}
+
+func signingMethod(algorithm Algorithm) jwt.SigningMethod {
+	switch algorithm {
+	case RS256:
+		return jwt.SigningMethodRS256
+	case PS256:
+		return jwt.SigningMethodPS256
+	}
+	return jwt.SigningMethodEdDSA
+}
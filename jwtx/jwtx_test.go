@@ -0,0 +1,115 @@
+package jwtx
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/nuntiodev/x/cryptox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigner(t *testing.T) {
+	rsaKey, _, err := cryptox.GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	keys := []Key{
+		{Algorithm: RS256, PrivateKey: rsaKey},
+		{Algorithm: PS256, PrivateKey: rsaKey, ID: "pss"},
+		{Algorithm: EdDSA, PrivateKey: edKey},
+	}
+	for _, key := range keys {
+		t.Run(string(key.Algorithm), func(t *testing.T) {
+			signer, err := New(key)
+			assert.NoError(t, err)
+			token, err := signer.IssueAccessToken("user-1", []string{"api"}, map[string]interface{}{"role": "admin"})
+			assert.NoError(t, err)
+			claims, err := signer.Verify(token, cryptox.TokenTypeAccess, "api")
+			assert.NoError(t, err)
+			assert.Equal(t, "user-1", claims["sub"])
+			assert.Equal(t, cryptox.Issuer, claims["iss"])
+			assert.Equal(t, "admin", claims["role"])
+			_, err = signer.Verify(token, cryptox.TokenTypeRefresh, "api")
+			assert.Error(t, err)
+			_, err = signer.Verify(token, cryptox.TokenTypeAccess, "other")
+			assert.Error(t, err)
+			// other services verify with the published keys
+			server := httptest.NewServer(Handler(signer, time.Hour))
+			defer server.Close()
+			response, err := http.Get(server.URL)
+			assert.NoError(t, err)
+			defer response.Body.Close()
+			assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+			var body json.RawMessage
+			assert.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+			jwks, err := keyfunc.NewJSON(body)
+			assert.NoError(t, err)
+			parsed, err := jwt.Parse(token, jwks.Keyfunc)
+			assert.NoError(t, err)
+			assert.True(t, parsed.Valid)
+			assert.Equal(t, signer.JWKS().Keys[0].KeyID, parsed.Header["kid"])
+		})
+	}
+}
+
+func TestSignerRotationAndErrors(t *testing.T) {
+	oldKey, _, err := cryptox.GenerateRsaKeyPair(2048)
+	assert.NoError(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	old, err := New(Key{Algorithm: RS256, PrivateKey: oldKey})
+	assert.NoError(t, err)
+	oldToken, err := old.IssueRefreshToken("user-1", nil, nil)
+	assert.NoError(t, err)
+	// the retired key is only published for verification
+	signer, err := New(Key{Algorithm: EdDSA, PrivateKey: newKey}, WithVerificationKeys(Key{Algorithm: RS256, PublicKey: &oldKey.PublicKey}))
+	assert.NoError(t, err)
+	assert.Len(t, signer.JWKS().Keys, 2)
+	_, err = signer.Verify(oldToken, cryptox.TokenTypeRefresh, "")
+	assert.NoError(t, err)
+	// the alg header must match the algorithm of the key
+	pss := jwt.NewWithClaims(jwt.SigningMethodPS256, jwt.MapClaims{"iss": cryptox.Issuer, "exp": time.Now().Add(time.Hour).Unix()})
+	for _, jwk := range signer.JWKS().Keys {
+		if jwk.Algorithm == string(RS256) {
+			pss.Header["kid"] = jwk.KeyID
+		}
+	}
+	forged, err := pss.SignedString(oldKey)
+	assert.NoError(t, err)
+	_, err = signer.Verify(forged, "", "")
+	assert.Error(t, err)
+	// expired tokens and other issuers are rejected
+	expired, err := New(Key{Algorithm: RS256, PrivateKey: oldKey}, WithClock(func() time.Time { return time.Now().Add(-time.Hour) }))
+	assert.NoError(t, err)
+	expiredToken, err := expired.IssueAccessToken("user-1", nil, nil)
+	assert.NoError(t, err)
+	_, err = old.Verify(expiredToken, "", "")
+	assert.Error(t, err)
+	other, err := New(Key{Algorithm: RS256, PrivateKey: oldKey}, WithIssuer("other"))
+	assert.NoError(t, err)
+	otherToken, err := other.IssueAccessToken("user-1", nil, nil)
+	assert.NoError(t, err)
+	_, err = old.Verify(otherToken, "", "")
+	assert.Error(t, err)
+	_, err = old.IssueAccessToken("user-1", nil, map[string]interface{}{"sub": "user-2"})
+	assert.Error(t, err)
+	_, err = New(Key{Algorithm: EdDSA, PrivateKey: oldKey})
+	assert.Error(t, err)
+	_, err = New(Key{Algorithm: RS256, PublicKey: &oldKey.PublicKey})
+	assert.Error(t, err)
+}
+
+func TestThumbprint(t *testing.T) {
+	// example of RFC 8037 appendix A.3
+	var x = []byte{0xd7, 0x5a, 0x98, 0x01, 0x82, 0xb1, 0x0a, 0xb7, 0xd5, 0x4b, 0xfe, 0xd3, 0xc9, 0x64, 0x07, 0x3a, 0x0e, 0xe1, 0x72, 0xf3, 0xda, 0xa6, 0x23, 0x25, 0xaf, 0x02, 0x1a, 0x68, 0xf7, 0x07, 0x51, 0x1a}
+	thumbprint, err := Thumbprint(ed25519.PublicKey(x))
+	assert.NoError(t, err)
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", thumbprint)
+}
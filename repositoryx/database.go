@@ -5,6 +5,7 @@ import (
 
 	"github.com/nuntiodev/x/repositoryx/mongo_client"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
@@ -18,6 +19,6 @@ func CreateDatabase(zapLog *zap.Logger) (*Database, error) {
 	}, nil
 }
 
-func (db *Database) CreateMongoClient(ctx context.Context, retry *int) (*mongo.Client, error) {
-	return mongo_client.CreateMongoClient(ctx, db.zapLog, retry)
+func (db *Database) CreateMongoClient(ctx context.Context, retry *int, opts ...*options.ClientOptions) (*mongo.Client, error) {
+	return mongo_client.CreateMongoClient(ctx, db.zapLog, retry, opts...)
 }
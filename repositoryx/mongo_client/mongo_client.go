@@ -41,7 +41,9 @@ func initializeMongoClient() error {
 	return nil
 }
 
-func CreateMongoClient(ctx context.Context, zapLog *zap.Logger, retry *int) (*mongo.Client, error) {
+// CreateMongoClient connects to the MongoDB configured by the environment. opts are applied after the uri,
+// eg. options.Client().SetRegistry(cryptox.NewBSONRegistry(crypto)) to encrypt Stringx fields automatically.
+func CreateMongoClient(ctx context.Context, zapLog *zap.Logger, retry *int, opts ...*options.ClientOptions) (*mongo.Client, error) {
 	zapLog.Info("trying to create mongo client...")
 	if err := initializeMongoClient(); err != nil {
 		return nil, err
@@ -56,9 +58,9 @@ func CreateMongoClient(ctx context.Context, zapLog *zap.Logger, retry *int) (*mo
 	}
 	var client *mongo.Client
 	if err := retryx.Retry(withRetry, time.Second*5, func() (err error) {
-		client, err = mongo.Connect(ctx, options.Client().ApplyURI(
+		client, err = mongo.Connect(ctx, append([]*options.ClientOptions{options.Client().ApplyURI(
 			mongoUri,
-		))
+		)}, opts...)...)
 		if err != nil {
 			zapLog.Error(fmt.Sprintf("could not connect to MongoDB with err: %v", err))
 			return err